@@ -103,12 +103,12 @@ func (h *Handler) ServeTags(w http.ResponseWriter, r *http.Request) {
 
 	var valueSQL string
 	if len(usedTags) == 0 {
-		valueSQL = "splitByChar('=', Tag1)[1] AS value"
+		valueSQL = finder.TagNameSQL("Tag1") + " AS value"
 		if tagPrefix != "" {
 			where.Andf("Tag1 LIKE %s", finder.Q(tagPrefix+"%"))
 		}
 	} else {
-		valueSQL = "splitByChar('=', arrayJoin(Tags))[1] AS value"
+		valueSQL = finder.TagNameSQL("arrayJoin(Tags)") + " AS value"
 		if tagPrefix != "" {
 			where.Andf("arrayJoin(Tags) LIKE %s", finder.Q(tagPrefix+"%"))
 		}
@@ -216,14 +216,12 @@ func (h *Handler) ServeValues(w http.ResponseWriter, r *http.Request) {
 		where.And(exprWhere)
 	}
 
-	var valueSQL string
-	if len(usedTags) == 0 {
-		valueSQL = "splitByChar('=', Tag1)[2] AS value"
-		where.Andf("Tag1 LIKE %s", finder.Q(tag+"="+valuePrefix+"%"))
-	} else {
-		valueSQL = "splitByChar('=', arrayJoin(Tags))[2] AS value"
-		where.Andf("arrayJoin(Tags) LIKE %s", finder.Q(tag+"="+valuePrefix+"%"))
+	column := "Tag1"
+	if len(usedTags) > 0 {
+		column = "arrayJoin(Tags)"
 	}
+	valueSQL := finder.TagValueSQL(column, tag) + " AS value"
+	where.And(finder.TagValueWhere(column, tag, valuePrefix))
 
 	fromDate := time.Now().AddDate(0, 0, -h.config.ClickHouse.TaggedAutocompleDays)
 	where.Andf("Date >= '%s'", fromDate.Format("2006-01-02"))
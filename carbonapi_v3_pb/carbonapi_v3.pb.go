@@ -0,0 +1,2858 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: carbonapi_v3.proto
+
+/*
+	Package carbonapi_v3_pb is a generated protocol buffer package.
+
+	It is generated from these files:
+		carbonapi_v3.proto
+
+	It has these top-level messages:
+		FetchRequest
+		MultiFetchRequest
+		FetchResponse
+		MultiFetchResponse
+		MultiGlobRequest
+		GlobMatch
+		GlobResponse
+		MultiGlobResponse
+		MultiMetricsInfoRequest
+		Retention
+		MetricsInfoResponse
+		MultiMetricsInfoResponse
+*/
+package carbonapi_v3_pb
+
+import proto "github.com/gogo/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import binary "encoding/binary"
+
+import io "io"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+
+type FetchRequest struct {
+	Name                    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	StartTime               int64  `protobuf:"varint,2,opt,name=startTime,proto3" json:"startTime,omitempty"`
+	StopTime                int64  `protobuf:"varint,3,opt,name=stopTime,proto3" json:"stopTime,omitempty"`
+	HighPrecisionTimestamps bool   `protobuf:"varint,4,opt,name=highPrecisionTimestamps,proto3" json:"highPrecisionTimestamps,omitempty"`
+	PathExpression          string `protobuf:"bytes,5,opt,name=pathExpression,proto3" json:"pathExpression,omitempty"`
+	MaxDataPoints           int64  `protobuf:"varint,7,opt,name=maxDataPoints,proto3" json:"maxDataPoints,omitempty"`
+}
+
+func (m *FetchRequest) Reset()                    { *m = FetchRequest{} }
+func (m *FetchRequest) String() string            { return proto.CompactTextString(m) }
+func (*FetchRequest) ProtoMessage()               {}
+func (*FetchRequest) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{0} }
+
+func (m *FetchRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *FetchRequest) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *FetchRequest) GetStopTime() int64 {
+	if m != nil {
+		return m.StopTime
+	}
+	return 0
+}
+
+func (m *FetchRequest) GetHighPrecisionTimestamps() bool {
+	if m != nil {
+		return m.HighPrecisionTimestamps
+	}
+	return false
+}
+
+func (m *FetchRequest) GetPathExpression() string {
+	if m != nil {
+		return m.PathExpression
+	}
+	return ""
+}
+
+func (m *FetchRequest) GetMaxDataPoints() int64 {
+	if m != nil {
+		return m.MaxDataPoints
+	}
+	return 0
+}
+
+type MultiFetchRequest struct {
+	Metrics []*FetchRequest `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+}
+
+func (m *MultiFetchRequest) Reset()                    { *m = MultiFetchRequest{} }
+func (m *MultiFetchRequest) String() string            { return proto.CompactTextString(m) }
+func (*MultiFetchRequest) ProtoMessage()               {}
+func (*MultiFetchRequest) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{1} }
+
+func (m *MultiFetchRequest) GetMetrics() []*FetchRequest {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+type FetchResponse struct {
+	Name                    string    `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	PathExpression          string    `protobuf:"bytes,2,opt,name=pathExpression,proto3" json:"pathExpression,omitempty"`
+	ConsolidationFunc       string    `protobuf:"bytes,3,opt,name=consolidationFunc,proto3" json:"consolidationFunc,omitempty"`
+	StartTime               int64     `protobuf:"varint,4,opt,name=startTime,proto3" json:"startTime,omitempty"`
+	StopTime                int64     `protobuf:"varint,5,opt,name=stopTime,proto3" json:"stopTime,omitempty"`
+	StepTime                int64     `protobuf:"varint,6,opt,name=stepTime,proto3" json:"stepTime,omitempty"`
+	XFilesFactor            float32   `protobuf:"fixed32,7,opt,name=xFilesFactor,proto3" json:"xFilesFactor,omitempty"`
+	HighPrecisionTimestamps bool      `protobuf:"varint,8,opt,name=highPrecisionTimestamps,proto3" json:"highPrecisionTimestamps,omitempty"`
+	Values                  []float64 `protobuf:"fixed64,9,rep,packed,name=values" json:"values,omitempty"`
+	AppliedFunctions        []string  `protobuf:"bytes,10,rep,name=appliedFunctions" json:"appliedFunctions,omitempty"`
+	RequestStartTime        int64     `protobuf:"varint,11,opt,name=requestStartTime,proto3" json:"requestStartTime,omitempty"`
+	RequestStopTime         int64     `protobuf:"varint,12,opt,name=requestStopTime,proto3" json:"requestStopTime,omitempty"`
+}
+
+func (m *FetchResponse) Reset()                    { *m = FetchResponse{} }
+func (m *FetchResponse) String() string            { return proto.CompactTextString(m) }
+func (*FetchResponse) ProtoMessage()               {}
+func (*FetchResponse) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{2} }
+
+func (m *FetchResponse) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *FetchResponse) GetPathExpression() string {
+	if m != nil {
+		return m.PathExpression
+	}
+	return ""
+}
+
+func (m *FetchResponse) GetConsolidationFunc() string {
+	if m != nil {
+		return m.ConsolidationFunc
+	}
+	return ""
+}
+
+func (m *FetchResponse) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *FetchResponse) GetStopTime() int64 {
+	if m != nil {
+		return m.StopTime
+	}
+	return 0
+}
+
+func (m *FetchResponse) GetStepTime() int64 {
+	if m != nil {
+		return m.StepTime
+	}
+	return 0
+}
+
+func (m *FetchResponse) GetXFilesFactor() float32 {
+	if m != nil {
+		return m.XFilesFactor
+	}
+	return 0
+}
+
+func (m *FetchResponse) GetHighPrecisionTimestamps() bool {
+	if m != nil {
+		return m.HighPrecisionTimestamps
+	}
+	return false
+}
+
+func (m *FetchResponse) GetValues() []float64 {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+func (m *FetchResponse) GetAppliedFunctions() []string {
+	if m != nil {
+		return m.AppliedFunctions
+	}
+	return nil
+}
+
+func (m *FetchResponse) GetRequestStartTime() int64 {
+	if m != nil {
+		return m.RequestStartTime
+	}
+	return 0
+}
+
+func (m *FetchResponse) GetRequestStopTime() int64 {
+	if m != nil {
+		return m.RequestStopTime
+	}
+	return 0
+}
+
+type MultiFetchResponse struct {
+	Metrics []*FetchResponse `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+}
+
+func (m *MultiFetchResponse) Reset()                    { *m = MultiFetchResponse{} }
+func (m *MultiFetchResponse) String() string            { return proto.CompactTextString(m) }
+func (*MultiFetchResponse) ProtoMessage()               {}
+func (*MultiFetchResponse) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{3} }
+
+func (m *MultiFetchResponse) GetMetrics() []*FetchResponse {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+type MultiGlobRequest struct {
+	Metrics   []string `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+	StartTime int64    `protobuf:"varint,2,opt,name=startTime,proto3" json:"startTime,omitempty"`
+	StopTime  int64    `protobuf:"varint,3,opt,name=stopTime,proto3" json:"stopTime,omitempty"`
+}
+
+func (m *MultiGlobRequest) Reset()                    { *m = MultiGlobRequest{} }
+func (m *MultiGlobRequest) String() string            { return proto.CompactTextString(m) }
+func (*MultiGlobRequest) ProtoMessage()               {}
+func (*MultiGlobRequest) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{4} }
+
+func (m *MultiGlobRequest) GetMetrics() []string {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+func (m *MultiGlobRequest) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *MultiGlobRequest) GetStopTime() int64 {
+	if m != nil {
+		return m.StopTime
+	}
+	return 0
+}
+
+type GlobMatch struct {
+	Path   string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	IsLeaf bool   `protobuf:"varint,2,opt,name=isLeaf,proto3" json:"isLeaf,omitempty"`
+}
+
+func (m *GlobMatch) Reset()                    { *m = GlobMatch{} }
+func (m *GlobMatch) String() string            { return proto.CompactTextString(m) }
+func (*GlobMatch) ProtoMessage()               {}
+func (*GlobMatch) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{5} }
+
+func (m *GlobMatch) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *GlobMatch) GetIsLeaf() bool {
+	if m != nil {
+		return m.IsLeaf
+	}
+	return false
+}
+
+type GlobResponse struct {
+	Name    string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Matches []*GlobMatch `protobuf:"bytes,2,rep,name=matches" json:"matches,omitempty"`
+}
+
+func (m *GlobResponse) Reset()                    { *m = GlobResponse{} }
+func (m *GlobResponse) String() string            { return proto.CompactTextString(m) }
+func (*GlobResponse) ProtoMessage()               {}
+func (*GlobResponse) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{6} }
+
+func (m *GlobResponse) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *GlobResponse) GetMatches() []*GlobMatch {
+	if m != nil {
+		return m.Matches
+	}
+	return nil
+}
+
+type MultiGlobResponse struct {
+	Metrics []*GlobResponse `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+}
+
+func (m *MultiGlobResponse) Reset()                    { *m = MultiGlobResponse{} }
+func (m *MultiGlobResponse) String() string            { return proto.CompactTextString(m) }
+func (*MultiGlobResponse) ProtoMessage()               {}
+func (*MultiGlobResponse) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{7} }
+
+func (m *MultiGlobResponse) GetMetrics() []*GlobResponse {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+type MultiMetricsInfoRequest struct {
+	Names []string `protobuf:"bytes,1,rep,name=names" json:"names,omitempty"`
+}
+
+func (m *MultiMetricsInfoRequest) Reset()         { *m = MultiMetricsInfoRequest{} }
+func (m *MultiMetricsInfoRequest) String() string { return proto.CompactTextString(m) }
+func (*MultiMetricsInfoRequest) ProtoMessage()    {}
+func (*MultiMetricsInfoRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptorCarbonapiV3, []int{8}
+}
+
+func (m *MultiMetricsInfoRequest) GetNames() []string {
+	if m != nil {
+		return m.Names
+	}
+	return nil
+}
+
+type Retention struct {
+	SecondsPerPoint int64 `protobuf:"varint,1,opt,name=secondsPerPoint,proto3" json:"secondsPerPoint,omitempty"`
+	NumberOfPoints  int64 `protobuf:"varint,2,opt,name=numberOfPoints,proto3" json:"numberOfPoints,omitempty"`
+}
+
+func (m *Retention) Reset()                    { *m = Retention{} }
+func (m *Retention) String() string            { return proto.CompactTextString(m) }
+func (*Retention) ProtoMessage()               {}
+func (*Retention) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{9} }
+
+func (m *Retention) GetSecondsPerPoint() int64 {
+	if m != nil {
+		return m.SecondsPerPoint
+	}
+	return 0
+}
+
+func (m *Retention) GetNumberOfPoints() int64 {
+	if m != nil {
+		return m.NumberOfPoints
+	}
+	return 0
+}
+
+type MetricsInfoResponse struct {
+	Name              string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ConsolidationFunc string       `protobuf:"bytes,2,opt,name=consolidationFunc,proto3" json:"consolidationFunc,omitempty"`
+	XFilesFactor      float32      `protobuf:"fixed32,3,opt,name=xFilesFactor,proto3" json:"xFilesFactor,omitempty"`
+	MaxRetention      int64        `protobuf:"varint,4,opt,name=maxRetention,proto3" json:"maxRetention,omitempty"`
+	Retentions        []*Retention `protobuf:"bytes,5,rep,name=retentions" json:"retentions,omitempty"`
+}
+
+func (m *MetricsInfoResponse) Reset()                    { *m = MetricsInfoResponse{} }
+func (m *MetricsInfoResponse) String() string            { return proto.CompactTextString(m) }
+func (*MetricsInfoResponse) ProtoMessage()               {}
+func (*MetricsInfoResponse) Descriptor() ([]byte, []int) { return fileDescriptorCarbonapiV3, []int{10} }
+
+func (m *MetricsInfoResponse) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *MetricsInfoResponse) GetConsolidationFunc() string {
+	if m != nil {
+		return m.ConsolidationFunc
+	}
+	return ""
+}
+
+func (m *MetricsInfoResponse) GetXFilesFactor() float32 {
+	if m != nil {
+		return m.XFilesFactor
+	}
+	return 0
+}
+
+func (m *MetricsInfoResponse) GetMaxRetention() int64 {
+	if m != nil {
+		return m.MaxRetention
+	}
+	return 0
+}
+
+func (m *MetricsInfoResponse) GetRetentions() []*Retention {
+	if m != nil {
+		return m.Retentions
+	}
+	return nil
+}
+
+type MultiMetricsInfoResponse struct {
+	Metrics []*MetricsInfoResponse `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+}
+
+func (m *MultiMetricsInfoResponse) Reset()         { *m = MultiMetricsInfoResponse{} }
+func (m *MultiMetricsInfoResponse) String() string { return proto.CompactTextString(m) }
+func (*MultiMetricsInfoResponse) ProtoMessage()    {}
+func (*MultiMetricsInfoResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptorCarbonapiV3, []int{11}
+}
+
+func (m *MultiMetricsInfoResponse) GetMetrics() []*MetricsInfoResponse {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*FetchRequest)(nil), "carbonapi_v3_pb.FetchRequest")
+	proto.RegisterType((*MultiFetchRequest)(nil), "carbonapi_v3_pb.MultiFetchRequest")
+	proto.RegisterType((*FetchResponse)(nil), "carbonapi_v3_pb.FetchResponse")
+	proto.RegisterType((*MultiFetchResponse)(nil), "carbonapi_v3_pb.MultiFetchResponse")
+	proto.RegisterType((*MultiGlobRequest)(nil), "carbonapi_v3_pb.MultiGlobRequest")
+	proto.RegisterType((*GlobMatch)(nil), "carbonapi_v3_pb.GlobMatch")
+	proto.RegisterType((*GlobResponse)(nil), "carbonapi_v3_pb.GlobResponse")
+	proto.RegisterType((*MultiGlobResponse)(nil), "carbonapi_v3_pb.MultiGlobResponse")
+	proto.RegisterType((*MultiMetricsInfoRequest)(nil), "carbonapi_v3_pb.MultiMetricsInfoRequest")
+	proto.RegisterType((*Retention)(nil), "carbonapi_v3_pb.Retention")
+	proto.RegisterType((*MetricsInfoResponse)(nil), "carbonapi_v3_pb.MetricsInfoResponse")
+	proto.RegisterType((*MultiMetricsInfoResponse)(nil), "carbonapi_v3_pb.MultiMetricsInfoResponse")
+}
+func (m *FetchRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FetchRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if m.StartTime != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StartTime))
+	}
+	if m.StopTime != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StopTime))
+	}
+	if m.HighPrecisionTimestamps {
+		dAtA[i] = 0x20
+		i++
+		if m.HighPrecisionTimestamps {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.PathExpression) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.PathExpression)))
+		i += copy(dAtA[i:], m.PathExpression)
+	}
+	if m.MaxDataPoints != 0 {
+		dAtA[i] = 0x38
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.MaxDataPoints))
+	}
+	return i, nil
+}
+
+func (m *MultiFetchRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MultiFetchRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, msg := range m.Metrics {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCarbonapiV3(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *FetchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FetchResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.PathExpression) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.PathExpression)))
+		i += copy(dAtA[i:], m.PathExpression)
+	}
+	if len(m.ConsolidationFunc) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.ConsolidationFunc)))
+		i += copy(dAtA[i:], m.ConsolidationFunc)
+	}
+	if m.StartTime != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StartTime))
+	}
+	if m.StopTime != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StopTime))
+	}
+	if m.StepTime != 0 {
+		dAtA[i] = 0x30
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StepTime))
+	}
+	if m.XFilesFactor != 0 {
+		dAtA[i] = 0x3d
+		i++
+		binary.LittleEndian.PutUint32(dAtA[i:], uint32(math.Float32bits(float32(m.XFilesFactor))))
+		i += 4
+	}
+	if m.HighPrecisionTimestamps {
+		dAtA[i] = 0x40
+		i++
+		if m.HighPrecisionTimestamps {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Values) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.Values)*8))
+		for _, num := range m.Values {
+			f1 := math.Float64bits(float64(num))
+			binary.LittleEndian.PutUint64(dAtA[i:], uint64(f1))
+			i += 8
+		}
+	}
+	if len(m.AppliedFunctions) > 0 {
+		for _, s := range m.AppliedFunctions {
+			dAtA[i] = 0x52
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.RequestStartTime != 0 {
+		dAtA[i] = 0x58
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.RequestStartTime))
+	}
+	if m.RequestStopTime != 0 {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.RequestStopTime))
+	}
+	return i, nil
+}
+
+func (m *MultiFetchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MultiFetchResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, msg := range m.Metrics {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCarbonapiV3(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *MultiGlobRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MultiGlobRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, s := range m.Metrics {
+			dAtA[i] = 0xa
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.StartTime != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StartTime))
+	}
+	if m.StopTime != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.StopTime))
+	}
+	return i, nil
+}
+
+func (m *GlobMatch) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GlobMatch) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Path) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.Path)))
+		i += copy(dAtA[i:], m.Path)
+	}
+	if m.IsLeaf {
+		dAtA[i] = 0x10
+		i++
+		if m.IsLeaf {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	return i, nil
+}
+
+func (m *GlobResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GlobResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.Matches) > 0 {
+		for _, msg := range m.Matches {
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintCarbonapiV3(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *MultiGlobResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MultiGlobResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, msg := range m.Metrics {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCarbonapiV3(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *MultiMetricsInfoRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MultiMetricsInfoRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Names) > 0 {
+		for _, s := range m.Names {
+			dAtA[i] = 0xa
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *Retention) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Retention) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.SecondsPerPoint != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.SecondsPerPoint))
+	}
+	if m.NumberOfPoints != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.NumberOfPoints))
+	}
+	return i, nil
+}
+
+func (m *MetricsInfoResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MetricsInfoResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.ConsolidationFunc) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(len(m.ConsolidationFunc)))
+		i += copy(dAtA[i:], m.ConsolidationFunc)
+	}
+	if m.XFilesFactor != 0 {
+		dAtA[i] = 0x1d
+		i++
+		binary.LittleEndian.PutUint32(dAtA[i:], uint32(math.Float32bits(float32(m.XFilesFactor))))
+		i += 4
+	}
+	if m.MaxRetention != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintCarbonapiV3(dAtA, i, uint64(m.MaxRetention))
+	}
+	if len(m.Retentions) > 0 {
+		for _, msg := range m.Retentions {
+			dAtA[i] = 0x2a
+			i++
+			i = encodeVarintCarbonapiV3(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *MultiMetricsInfoResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MultiMetricsInfoResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, msg := range m.Metrics {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCarbonapiV3(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func encodeVarintCarbonapiV3(dAtA []byte, offset int, v uint64) int {
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return offset + 1
+}
+func (m *FetchRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	if m.StartTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StartTime))
+	}
+	if m.StopTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StopTime))
+	}
+	if m.HighPrecisionTimestamps {
+		n += 2
+	}
+	l = len(m.PathExpression)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	if m.MaxDataPoints != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.MaxDataPoints))
+	}
+	return n
+}
+
+func (m *MultiFetchRequest) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, e := range m.Metrics {
+			l = e.Size()
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *FetchResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	l = len(m.PathExpression)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	l = len(m.ConsolidationFunc)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	if m.StartTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StartTime))
+	}
+	if m.StopTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StopTime))
+	}
+	if m.StepTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StepTime))
+	}
+	if m.XFilesFactor != 0 {
+		n += 5
+	}
+	if m.HighPrecisionTimestamps {
+		n += 2
+	}
+	if len(m.Values) > 0 {
+		n += 1 + sovCarbonapiV3(uint64(len(m.Values)*8)) + len(m.Values)*8
+	}
+	if len(m.AppliedFunctions) > 0 {
+		for _, s := range m.AppliedFunctions {
+			l = len(s)
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	if m.RequestStartTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.RequestStartTime))
+	}
+	if m.RequestStopTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.RequestStopTime))
+	}
+	return n
+}
+
+func (m *MultiFetchResponse) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, e := range m.Metrics {
+			l = e.Size()
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *MultiGlobRequest) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, s := range m.Metrics {
+			l = len(s)
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	if m.StartTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StartTime))
+	}
+	if m.StopTime != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.StopTime))
+	}
+	return n
+}
+
+func (m *GlobMatch) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Path)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	if m.IsLeaf {
+		n += 2
+	}
+	return n
+}
+
+func (m *GlobResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	if len(m.Matches) > 0 {
+		for _, e := range m.Matches {
+			l = e.Size()
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *MultiGlobResponse) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, e := range m.Metrics {
+			l = e.Size()
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *MultiMetricsInfoRequest) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Names) > 0 {
+		for _, s := range m.Names {
+			l = len(s)
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *Retention) Size() (n int) {
+	var l int
+	_ = l
+	if m.SecondsPerPoint != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.SecondsPerPoint))
+	}
+	if m.NumberOfPoints != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.NumberOfPoints))
+	}
+	return n
+}
+
+func (m *MetricsInfoResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	l = len(m.ConsolidationFunc)
+	if l > 0 {
+		n += 1 + l + sovCarbonapiV3(uint64(l))
+	}
+	if m.XFilesFactor != 0 {
+		n += 5
+	}
+	if m.MaxRetention != 0 {
+		n += 1 + sovCarbonapiV3(uint64(m.MaxRetention))
+	}
+	if len(m.Retentions) > 0 {
+		for _, e := range m.Retentions {
+			l = e.Size()
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *MultiMetricsInfoResponse) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Metrics) > 0 {
+		for _, e := range m.Metrics {
+			l = e.Size()
+			n += 1 + l + sovCarbonapiV3(uint64(l))
+		}
+	}
+	return n
+}
+
+func sovCarbonapiV3(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozCarbonapiV3(x uint64) (n int) {
+	return sovCarbonapiV3(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *FetchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FetchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FetchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartTime", wireType)
+			}
+			m.StartTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StartTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StopTime", wireType)
+			}
+			m.StopTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StopTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HighPrecisionTimestamps", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.HighPrecisionTimestamps = bool(v != 0)
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PathExpression", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PathExpression = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxDataPoints", wireType)
+			}
+			m.MaxDataPoints = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxDataPoints |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MultiFetchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MultiFetchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MultiFetchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metrics = append(m.Metrics, &FetchRequest{})
+			if err := m.Metrics[len(m.Metrics)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FetchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FetchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FetchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PathExpression", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PathExpression = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ConsolidationFunc", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ConsolidationFunc = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartTime", wireType)
+			}
+			m.StartTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StartTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StopTime", wireType)
+			}
+			m.StopTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StopTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StepTime", wireType)
+			}
+			m.StepTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StepTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 5 {
+				return fmt.Errorf("proto: wrong wireType = %d for field XFilesFactor", wireType)
+			}
+			var v uint32
+			if (iNdEx + 4) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint32(binary.LittleEndian.Uint32(dAtA[iNdEx:]))
+			iNdEx += 4
+			m.XFilesFactor = float32(math.Float32frombits(v))
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HighPrecisionTimestamps", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.HighPrecisionTimestamps = bool(v != 0)
+		case 9:
+			if wireType == 1 {
+				var v uint64
+				if (iNdEx + 8) > l {
+					return io.ErrUnexpectedEOF
+				}
+				v = uint64(binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+				iNdEx += 8
+				v2 := float64(math.Float64frombits(v))
+				m.Values = append(m.Values, v2)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowCarbonapiV3
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthCarbonapiV3
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					if (iNdEx + 8) > l {
+						return io.ErrUnexpectedEOF
+					}
+					v = uint64(binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+					iNdEx += 8
+					v2 := float64(math.Float64frombits(v))
+					m.Values = append(m.Values, v2)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Values", wireType)
+			}
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AppliedFunctions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AppliedFunctions = append(m.AppliedFunctions, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestStartTime", wireType)
+			}
+			m.RequestStartTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RequestStartTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestStopTime", wireType)
+			}
+			m.RequestStopTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RequestStopTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MultiFetchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MultiFetchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MultiFetchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metrics = append(m.Metrics, &FetchResponse{})
+			if err := m.Metrics[len(m.Metrics)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MultiGlobRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MultiGlobRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MultiGlobRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metrics = append(m.Metrics, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartTime", wireType)
+			}
+			m.StartTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StartTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StopTime", wireType)
+			}
+			m.StopTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StopTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GlobMatch) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GlobMatch: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GlobMatch: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Path", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Path = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IsLeaf", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.IsLeaf = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GlobResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GlobResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GlobResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matches", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matches = append(m.Matches, &GlobMatch{})
+			if err := m.Matches[len(m.Matches)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MultiGlobResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MultiGlobResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MultiGlobResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metrics = append(m.Metrics, &GlobResponse{})
+			if err := m.Metrics[len(m.Metrics)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MultiMetricsInfoRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MultiMetricsInfoRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MultiMetricsInfoRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Names", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Names = append(m.Names, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Retention) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Retention: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Retention: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SecondsPerPoint", wireType)
+			}
+			m.SecondsPerPoint = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SecondsPerPoint |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NumberOfPoints", wireType)
+			}
+			m.NumberOfPoints = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NumberOfPoints |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MetricsInfoResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MetricsInfoResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MetricsInfoResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ConsolidationFunc", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ConsolidationFunc = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 5 {
+				return fmt.Errorf("proto: wrong wireType = %d for field XFilesFactor", wireType)
+			}
+			var v uint32
+			if (iNdEx + 4) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint32(binary.LittleEndian.Uint32(dAtA[iNdEx:]))
+			iNdEx += 4
+			m.XFilesFactor = float32(math.Float32frombits(v))
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxRetention", wireType)
+			}
+			m.MaxRetention = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxRetention |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Retentions", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Retentions = append(m.Retentions, &Retention{})
+			if err := m.Retentions[len(m.Retentions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MultiMetricsInfoResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MultiMetricsInfoResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MultiMetricsInfoResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metrics = append(m.Metrics, &MetricsInfoResponse{})
+			if err := m.Metrics[len(m.Metrics)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCarbonapiV3(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCarbonapiV3
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipCarbonapiV3(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowCarbonapiV3
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowCarbonapiV3
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			iNdEx += length
+			if length < 0 {
+				return 0, ErrInvalidLengthCarbonapiV3
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowCarbonapiV3
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipCarbonapiV3(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthCarbonapiV3 = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowCarbonapiV3   = fmt.Errorf("proto: integer overflow")
+)
+
+func init() { proto.RegisterFile("carbonapi_v3.proto", fileDescriptorCarbonapiV3) }
+
+var fileDescriptorCarbonapiV3 = []byte{
+	// 634 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x55, 0x5f, 0x6b, 0x13, 0x4f,
+	0x14, 0x65, 0xb3, 0x4d, 0x9b, 0xbd, 0x4d, 0x7f, 0x6d, 0xe7, 0x27, 0x76, 0x28, 0x1a, 0x96, 0xa5,
+	0xc8, 0x22, 0x52, 0xc1, 0x0a, 0x2d, 0x3e, 0xf8, 0x20, 0x1a, 0x11, 0x5a, 0x2d, 0xa3, 0x0f, 0x22,
+	0x48, 0x99, 0x6c, 0x27, 0x66, 0x60, 0x77, 0x66, 0xdd, 0x99, 0x94, 0x7c, 0x43, 0x7d, 0xf4, 0xdd,
+	0x17, 0x29, 0x7e, 0x10, 0x99, 0xd9, 0x3f, 0xc9, 0xee, 0x26, 0x41, 0x7c, 0xcb, 0x3d, 0xf7, 0xee,
+	0xdd, 0x7b, 0xee, 0x39, 0x77, 0x03, 0x28, 0xa2, 0xd9, 0x48, 0x0a, 0x9a, 0xf2, 0xab, 0x9b, 0x93,
+	0xe3, 0x34, 0x93, 0x5a, 0xa2, 0xdd, 0x45, 0xec, 0x2a, 0x1d, 0x05, 0xbf, 0x1d, 0xe8, 0x0f, 0x99,
+	0x8e, 0x26, 0x84, 0x7d, 0x9d, 0x32, 0xa5, 0x11, 0x82, 0x0d, 0x41, 0x13, 0x86, 0x1d, 0xdf, 0x09,
+	0x3d, 0x62, 0x7f, 0xa3, 0x7b, 0xe0, 0x29, 0x4d, 0x33, 0xfd, 0x81, 0x27, 0x0c, 0x77, 0x7c, 0x27,
+	0x74, 0xc9, 0x1c, 0x40, 0x87, 0xd0, 0x53, 0x5a, 0xa6, 0x36, 0xe9, 0xda, 0x64, 0x15, 0xa3, 0x33,
+	0x38, 0x98, 0xf0, 0x2f, 0x93, 0xcb, 0x8c, 0x45, 0x5c, 0x71, 0x29, 0x0c, 0xa8, 0x34, 0x4d, 0x52,
+	0x85, 0x37, 0x7c, 0x27, 0xec, 0x91, 0x55, 0x69, 0xf4, 0x00, 0xfe, 0x4b, 0xa9, 0x9e, 0xbc, 0x9a,
+	0xa5, 0x19, 0x53, 0x26, 0x87, 0xbb, 0x76, 0xa2, 0x06, 0x8a, 0x8e, 0x60, 0x27, 0xa1, 0xb3, 0x97,
+	0x54, 0xd3, 0x4b, 0xc9, 0x85, 0x56, 0x78, 0xcb, 0x8e, 0x50, 0x07, 0x83, 0x73, 0xd8, 0xbf, 0x98,
+	0xc6, 0x9a, 0xd7, 0xa8, 0x9e, 0xc2, 0x56, 0xc2, 0x74, 0xc6, 0x23, 0x85, 0x1d, 0xdf, 0x0d, 0xb7,
+	0x9f, 0xdc, 0x3f, 0x6e, 0xac, 0xe7, 0x78, 0xb1, 0x9e, 0x94, 0xd5, 0xc1, 0x37, 0x17, 0x76, 0x8a,
+	0x8c, 0x4a, 0xa5, 0x50, 0x6c, 0xe9, 0xd6, 0xda, 0x0c, 0x3a, 0x4b, 0x19, 0x3c, 0x82, 0xfd, 0x48,
+	0x0a, 0x25, 0x63, 0x7e, 0x4d, 0x35, 0x97, 0x62, 0x38, 0x15, 0x91, 0x5d, 0xa4, 0x47, 0xda, 0x89,
+	0xba, 0x16, 0x1b, 0xeb, 0xb4, 0xe8, 0x36, 0xb4, 0xb0, 0x39, 0x96, 0xe7, 0x36, 0xcb, 0x5c, 0x1e,
+	0xa3, 0x00, 0xfa, 0xb3, 0x21, 0x8f, 0x99, 0x1a, 0xd2, 0x48, 0xcb, 0xcc, 0x2e, 0xb1, 0x43, 0x6a,
+	0xd8, 0x3a, 0x2d, 0x7b, 0xeb, 0xb5, 0xbc, 0x0b, 0x9b, 0x37, 0x34, 0x9e, 0x32, 0x85, 0x3d, 0xdf,
+	0x0d, 0x1d, 0x52, 0x44, 0xe8, 0x21, 0xec, 0xd1, 0x34, 0x8d, 0x39, 0xbb, 0x36, 0xd4, 0x0c, 0x45,
+	0x85, 0xc1, 0x77, 0x43, 0x8f, 0xb4, 0x70, 0x53, 0x9b, 0xe5, 0x3a, 0xbc, 0xaf, 0xe8, 0x6f, 0x5b,
+	0x16, 0x2d, 0x1c, 0x85, 0xb0, 0x5b, 0x61, 0xc5, 0x32, 0xfa, 0xb6, 0xb4, 0x09, 0x07, 0x6f, 0x01,
+	0x2d, 0xfa, 0xa2, 0x50, 0xf3, 0xac, 0x69, 0x8c, 0xc1, 0x2a, 0x63, 0xe4, 0x0f, 0xcc, 0x9d, 0x31,
+	0x86, 0x3d, 0xdb, 0xef, 0x75, 0x2c, 0x47, 0xa5, 0xcd, 0x70, 0xbd, 0x9b, 0x57, 0x55, 0xff, 0xfb,
+	0x5d, 0x05, 0xa7, 0xe0, 0x99, 0x57, 0x5c, 0x50, 0x1d, 0x4d, 0x8c, 0xf9, 0x8c, 0xa5, 0x4a, 0xf3,
+	0x99, 0xdf, 0x66, 0xe5, 0x5c, 0x9d, 0x33, 0x3a, 0xb6, 0x7d, 0x7b, 0xa4, 0x88, 0x82, 0x8f, 0xd0,
+	0xcf, 0x67, 0x5b, 0x63, 0xdc, 0xa7, 0xb0, 0x95, 0x98, 0xc6, 0x4c, 0xe1, 0x8e, 0xa5, 0x7f, 0xd8,
+	0xa2, 0x5f, 0xbd, 0x9c, 0x94, 0xa5, 0xd5, 0x89, 0xd5, 0xda, 0xff, 0xc5, 0x89, 0x2d, 0xd6, 0xcf,
+	0x17, 0xf9, 0x18, 0x0e, 0x6c, 0xb7, 0x8b, 0x3c, 0x7e, 0x23, 0xc6, 0xb2, 0xdc, 0xe7, 0x1d, 0xe8,
+	0x9a, 0x31, 0xcb, 0x6d, 0xe6, 0x41, 0xf0, 0x19, 0x3c, 0xc2, 0x34, 0x13, 0xc6, 0x2d, 0xc6, 0x00,
+	0x8a, 0x45, 0x52, 0x5c, 0xab, 0x4b, 0x96, 0xd9, 0x4f, 0x80, 0x25, 0xe8, 0x92, 0x26, 0x6c, 0x8e,
+	0x54, 0x4c, 0x93, 0x11, 0xcb, 0xde, 0x8d, 0x8b, 0xef, 0x47, 0xae, 0x43, 0x03, 0x0d, 0x7e, 0x3a,
+	0xf0, 0x7f, 0x6d, 0x96, 0x35, 0xfb, 0x5b, 0x7a, 0xd0, 0x9d, 0x55, 0x07, 0xdd, 0x3c, 0x3d, 0x77,
+	0xc9, 0xe9, 0x05, 0xd0, 0x4f, 0xe8, 0xac, 0xe2, 0x57, 0xdc, 0x7d, 0x0d, 0x43, 0xcf, 0x00, 0xb2,
+	0x32, 0x50, 0xb8, 0xbb, 0x42, 0xb8, 0xaa, 0x9e, 0x2c, 0x54, 0x07, 0x9f, 0x00, 0xb7, 0xb7, 0x5d,
+	0x30, 0x7c, 0xde, 0x94, 0xf0, 0xa8, 0xd5, 0x74, 0xc9, 0x63, 0x95, 0x92, 0x2f, 0xfa, 0xdf, 0x6f,
+	0x07, 0xce, 0x8f, 0xdb, 0x81, 0xf3, 0xeb, 0x76, 0xe0, 0x8c, 0x36, 0xed, 0xff, 0xd0, 0xc9, 0x9f,
+	0x01, 0x00, 0x95, 0x2c, 0x12, 0x16, 0x9d, 0x06, 0x00, 0x00,
+}
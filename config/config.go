@@ -10,6 +10,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/lomik/graphite-clickhouse/helper/cache"
 	"github.com/lomik/graphite-clickhouse/helper/rollup"
 	"github.com/lomik/zapwriter"
 )
@@ -39,30 +40,41 @@ func (d *Duration) Value() time.Duration {
 }
 
 type Common struct {
-	Listen string `toml:"listen"`
-	// MetricPrefix   string    `toml:"metric-prefix"`
-	// MetricInterval *Duration `toml:"metric-interval"`
-	// MetricEndpoint string    `toml:"metric-endpoint"`
+	Listen          string           `toml:"listen"`
+	MetricPrefix    string           `toml:"metric-prefix"`
+	MetricInterval  *Duration        `toml:"metric-interval"`
+	MetricEndpoint  string           `toml:"metric-endpoint"`
 	MaxCPU          int              `toml:"max-cpu"`
+	ShutdownDelay   *Duration        `toml:"shutdown-delay"`
+	ShutdownTimeout *Duration        `toml:"shutdown-timeout"`
+	ReadyInterval   *Duration        `toml:"ready-check-interval"`
+	ReloadToken     string           `toml:"reload-token"`
 	TargetBlacklist []string         `toml:"target-blacklist"`
 	Blacklist       []*regexp.Regexp `toml:"-"` // compiled TargetBlacklist
 }
 
 type ClickHouse struct {
-	Url                  string    `toml:"url"`
-	DataTable            string    `toml:"data-table"`
-	DataTimeout          *Duration `toml:"data-timeout"`
-	TreeTable            string    `toml:"tree-table"`
-	DateTreeTable        string    `toml:"date-tree-table"`
-	DateTreeTableVersion int       `toml:"date-tree-table-version"`
-	TaggedTable          string    `toml:"tagged-table"`
-	TaggedAutocompleDays int       `toml:"tagged-autocomplete-days"`
-	ReverseTreeTable     string    `toml:"reverse-tree-table"`
-	TreeTimeout          *Duration `toml:"tree-timeout"`
-	TagTable             string    `toml:"tag-table"`
-	RollupConf           string    `toml:"rollup-conf"`
-	ExtraPrefix          string    `toml:"extra-prefix"`
-	ConnectTimeout       *Duration `toml:"connect-timeout"`
+	Url                          string    `toml:"url"`
+	DataTable                    string    `toml:"data-table"`
+	DataTimeout                  *Duration `toml:"data-timeout"`
+	DataRetention                *Duration `toml:"data-retention"`
+	TreeTable                    string    `toml:"tree-table"`
+	DateTreeTable                string    `toml:"date-tree-table"`
+	DateTreeTableVersion         int       `toml:"date-tree-table-version"`
+	TaggedTable                  string    `toml:"tagged-table"`
+	TaggedAutocompleDays         int       `toml:"tagged-autocomplete-days"`
+	ReverseTreeTable             string    `toml:"reverse-tree-table"`
+	TreeTimeout                  *Duration `toml:"tree-timeout"`
+	TagTable                     string    `toml:"tag-table"`
+	RollupConf                   string    `toml:"rollup-conf"`
+	ExtraPrefix                  string    `toml:"extra-prefix"`
+	ConnectTimeout               *Duration `toml:"connect-timeout"`
+	ExternalDataThreshold        int       `toml:"external-data-threshold"`
+	FindConcurrency              int       `toml:"find-concurrency"`
+	FindDefaultLookback          *Duration `toml:"find-default-lookback"`
+	DataChunks                   int       `toml:"data-chunks"`
+	RenderStreaming              bool      `toml:"render-streaming"`
+	AggregationPushdownThreshold int       `toml:"aggregation-pushdown-threshold"`
 }
 
 type Tags struct {
@@ -94,30 +106,77 @@ type DataTable struct {
 	TargetMatchAllRegexp *regexp.Regexp `toml:"-"`
 	RollupConf           string         `toml:"rollup-conf"`
 	Rollup               *rollup.Rollup `toml:"-"`
+	RollupPushdown       bool           `toml:"rollup-pushdown"`
+}
+
+type Limits struct {
+	MaxSeriesPerTarget  int `toml:"max-series-per-target"`
+	MaxMetricsPerRender int `toml:"max-metrics-per-render"`
+	MaxPointsPerRender  int `toml:"max-points-per-render"`
+	MaxQuerySize        int `toml:"max-query-size"`
+}
+
+type FindCache struct {
+	MaxSize     int64        `toml:"max-size"`
+	TreeTTL     *Duration    `toml:"tree-ttl"`
+	DateTreeTTL *Duration    `toml:"date-tree-ttl"`
+	TaggedTTL   *Duration    `toml:"tagged-ttl"`
+	Cache       *cache.Cache `toml:"-"` // nil if max-size is 0
+}
+
+type RenderCache struct {
+	MaxSize int64        `toml:"max-size"`
+	TTL     *Duration    `toml:"ttl"`
+	Cache   *cache.Cache `toml:"-"` // nil if max-size is 0
+}
+
+type PrometheusWrite struct {
+	Enabled         bool      `toml:"enabled"`
+	PointsTable     string    `toml:"points-table"`
+	TaggedTable     string    `toml:"tagged-table"`
+	SeriesTable     string    `toml:"series-table"`
+	BatchSize       int       `toml:"batch-size"`
+	FlushInterval   *Duration `toml:"flush-interval"`
+	QueueSize       int       `toml:"queue-size"`
+	QueueTimeout    *Duration `toml:"queue-timeout"`
+	SeriesCacheSize int       `toml:"series-cache-size"`
+	Threads         int       `toml:"threads"`
+	Retries         int       `toml:"retries"`
+	Timeout         *Duration `toml:"timeout"`
 }
 
 // Config ...
 type Config struct {
-	Common     Common             `toml:"common"`
-	ClickHouse ClickHouse         `toml:"clickhouse"`
-	DataTable  []DataTable        `toml:"data-table"`
-	Tags       Tags               `toml:"tags"`
-	Carbonlink Carbonlink         `toml:"carbonlink"`
-	Logging    []zapwriter.Config `toml:"logging"`
-	Rollup     *rollup.Rollup     `toml:"-"`
+	Common          Common             `toml:"common"`
+	ClickHouse      ClickHouse         `toml:"clickhouse"`
+	DataTable       []DataTable        `toml:"data-table"`
+	Tags            Tags               `toml:"tags"`
+	Carbonlink      Carbonlink         `toml:"carbonlink"`
+	Limits          Limits             `toml:"limits"`
+	FindCache       FindCache          `toml:"find-cache"`
+	RenderCache     RenderCache        `toml:"render-cache"`
+	PrometheusWrite PrometheusWrite    `toml:"prometheus-write"`
+	Logging         []zapwriter.Config `toml:"logging"`
+	Rollup          *rollup.Rollup     `toml:"-"`
 }
 
 // NewConfig ...
 func New() *Config {
 	cfg := &Config{
 		Common: Common{
-			Listen: ":9090",
-			// MetricPrefix: "carbon.graphite-clickhouse.{host}",
-			// MetricInterval: &Duration{
-			// 	Duration: time.Minute,
-			// },
-			// MetricEndpoint: MetricEndpointLocal,
-			MaxCPU: 1,
+			Listen:       ":9090",
+			MetricPrefix: "carbon.graphite-clickhouse.{host}",
+			MetricInterval: &Duration{
+				Duration: time.Minute,
+			},
+			MaxCPU:        1,
+			ShutdownDelay: &Duration{},
+			ShutdownTimeout: &Duration{
+				Duration: 30 * time.Second,
+			},
+			ReadyInterval: &Duration{
+				Duration: 10 * time.Second,
+			},
 		},
 		ClickHouse: ClickHouse{
 			Url: "http://localhost:8123",
@@ -126,14 +185,20 @@ func New() *Config {
 			DataTimeout: &Duration{
 				Duration: time.Minute,
 			},
-			TreeTable: "graphite_tree",
+			DataRetention: &Duration{},
+			TreeTable:     "graphite_tree",
 			TreeTimeout: &Duration{
 				Duration: time.Minute,
 			},
-			RollupConf:           "/etc/graphite-clickhouse/rollup.xml",
-			TagTable:             "",
-			TaggedAutocompleDays: 7,
-			ConnectTimeout:       &Duration{Duration: time.Second},
+			RollupConf:                   "/etc/graphite-clickhouse/rollup.xml",
+			TagTable:                     "",
+			TaggedAutocompleDays:         7,
+			ConnectTimeout:               &Duration{Duration: time.Second},
+			ExternalDataThreshold:        1000,
+			FindConcurrency:              8,
+			FindDefaultLookback:          &Duration{},
+			DataChunks:                   1,
+			AggregationPushdownThreshold: 1000,
 		},
 		Tags: Tags{
 			Date:  "2016-11-01",
@@ -146,6 +211,25 @@ func New() *Config {
 			QueryTimeout:   &Duration{Duration: 50 * time.Millisecond},
 			TotalTimeout:   &Duration{Duration: 500 * time.Millisecond},
 		},
+		FindCache: FindCache{
+			TreeTTL:     &Duration{Duration: time.Minute},
+			DateTreeTTL: &Duration{Duration: time.Minute},
+			TaggedTTL:   &Duration{Duration: time.Minute},
+		},
+		RenderCache: RenderCache{
+			TTL: &Duration{Duration: 10 * time.Second},
+		},
+		PrometheusWrite: PrometheusWrite{
+			PointsTable:     "graphite",
+			BatchSize:       100000,
+			FlushInterval:   &Duration{Duration: time.Second},
+			QueueSize:       100,
+			QueueTimeout:    &Duration{Duration: time.Second},
+			SeriesCacheSize: 1000000,
+			Threads:         1,
+			Retries:         3,
+			Timeout:         &Duration{Duration: time.Minute},
+		},
 		Logging: nil,
 	}
 
@@ -227,6 +311,14 @@ func ReadConfig(filename string) (*Config, error) {
 
 	cfg.Rollup = r
 
+	if cfg.FindCache.MaxSize > 0 {
+		cfg.FindCache.Cache = cache.New(cfg.FindCache.MaxSize)
+	}
+
+	if cfg.RenderCache.MaxSize > 0 {
+		cfg.RenderCache.Cache = cache.New(cfg.RenderCache.MaxSize)
+	}
+
 	l := len(cfg.Common.TargetBlacklist)
 	if l > 0 {
 		cfg.Common.Blacklist = make([]*regexp.Regexp, l)
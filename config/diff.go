@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// encodeLines returns config in TOML format as list of "section.key = value" lines
+func encodeLines(cfg *Config) []string {
+	buf := new(bytes.Buffer)
+	encoder := toml.NewEncoder(buf)
+	encoder.Indent = ""
+	encoder.Encode(cfg)
+
+	var section string
+	lines := make([]string, 0)
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[]")
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, section+"."+line)
+	}
+
+	if cfg.Rollup != nil {
+		rollup, _ := xml.Marshal(cfg.Rollup)
+		lines = append(lines, "rollup = "+string(rollup))
+	}
+	for _, t := range cfg.DataTable {
+		if t.Rollup != nil {
+			rollup, _ := xml.Marshal(t.Rollup)
+			lines = append(lines, "data-table."+t.Table+".rollup = "+string(rollup))
+		}
+	}
+
+	return lines
+}
+
+// Diff returns changed lines of new config comparing with old. Removed lines start with "-", added with "+"
+func Diff(old *Config, new *Config) []string {
+	oldLines := encodeLines(old)
+	newLines := encodeLines(new)
+
+	oldSet := make(map[string]bool)
+	for _, l := range oldLines {
+		oldSet[l] = true
+	}
+	newSet := make(map[string]bool)
+	for _, l := range newLines {
+		newSet[l] = true
+	}
+
+	diff := make([]string, 0)
+	for _, l := range oldLines {
+		if !newSet[l] && !strings.HasPrefix(l, "[") {
+			diff = append(diff, "- "+l)
+		}
+	}
+	for _, l := range newLines {
+		if !oldSet[l] && !strings.HasPrefix(l, "[") {
+			diff = append(diff, "+ "+l)
+		}
+	}
+
+	return diff
+}
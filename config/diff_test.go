@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	old := New()
+	new := New()
+
+	assert.Empty(Diff(old, new))
+
+	new.ClickHouse.DataTable = "graphite_new"
+	new.Common.TargetBlacklist = []string{"^foo"}
+
+	assert.Equal([]string{
+		`- clickhouse.data-table = "graphite"`,
+		`+ common.target-blacklist = ["^foo"]`,
+		`+ clickhouse.data-table = "graphite_new"`,
+	}, Diff(old, new))
+}
@@ -1,11 +1,14 @@
 package find
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 
 	"github.com/gogo/protobuf/proto"
 
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
 	"github.com/lomik/graphite-clickhouse/carbonzipperpb"
 	"github.com/lomik/graphite-clickhouse/config"
 	"github.com/lomik/graphite-clickhouse/finder"
@@ -19,8 +22,8 @@ type Find struct {
 	result  finder.Result
 }
 
-func New(config *config.Config, ctx context.Context, query string) (*Find, error) {
-	res, err := finder.Find(config, ctx, query, 0, 0)
+func New(config *config.Config, ctx context.Context, query string, from int64, until int64) (*Find, error) {
+	res, err := finder.Find(config, ctx, query, from, until)
 	if err != nil {
 		return nil, err
 	}
@@ -111,3 +114,75 @@ func (f *Find) WriteProtobuf(w io.Writer) error {
 
 	return nil
 }
+
+// GlobResponseV3 returns result as carbonapi v3 GlobResponse
+func (f *Find) GlobResponseV3() *carbonapi_v3_pb.GlobResponse {
+	rows := f.result.List()
+
+	response := &carbonapi_v3_pb.GlobResponse{
+		Name:    f.query,
+		Matches: make([]*carbonapi_v3_pb.GlobMatch, 0, len(rows)),
+	}
+
+	for i := 0; i < len(rows); i++ {
+		if len(rows[i]) == 0 {
+			continue
+		}
+
+		path, isLeaf := finder.Leaf(rows[i])
+
+		response.Matches = append(response.Matches, &carbonapi_v3_pb.GlobMatch{
+			Path:   string(path),
+			IsLeaf: isLeaf,
+		})
+	}
+
+	return response
+}
+
+type treeJSONNode struct {
+	AllowChildren int               `json:"allowChildren"`
+	Expandable    int               `json:"expandable"`
+	Leaf          int               `json:"leaf"`
+	ID            string            `json:"id"`
+	Text          string            `json:"text"`
+	Context       map[string]string `json:"context"`
+}
+
+// WriteJSON writes result in graphite-web "treejson" format
+func (f *Find) WriteJSON(w io.Writer) error {
+	rows := f.result.List()
+
+	nodes := make([]treeJSONNode, 0, len(rows))
+
+	for i := 0; i < len(rows); i++ {
+		if len(rows[i]) == 0 {
+			continue
+		}
+
+		path, isLeaf := finder.Leaf(rows[i])
+
+		node := treeJSONNode{
+			ID:      string(path),
+			Text:    string(path[bytes.LastIndexByte(path, '.')+1:]),
+			Context: map[string]string{},
+		}
+
+		if isLeaf {
+			node.Leaf = 1
+		} else {
+			node.AllowChildren = 1
+			node.Expandable = 1
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	body, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	return err
+}
@@ -1,8 +1,16 @@
 package find
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
 	"github.com/lomik/graphite-clickhouse/config"
 )
 
@@ -16,10 +24,72 @@ func NewHandler(config *config.Config) *Handler {
 	}
 }
 
+// parseRange returns from and until parameters. Absent until is now. If from is absent series of
+// last find-default-lookback are searched, zero range (all series from tree-table) if lookback is not configured
+func (h *Handler) parseRange(r *http.Request) (int64, int64, error) {
+	var from, until int64
+	var err error
+
+	if r.FormValue("from") != "" {
+		from, err = strconv.ParseInt(r.FormValue("from"), 10, 32)
+		if err != nil {
+			return 0, 0, errors.New("Bad request (malformed from)")
+		}
+	}
+
+	if r.FormValue("until") != "" {
+		until, err = strconv.ParseInt(r.FormValue("until"), 10, 32)
+		if err != nil {
+			return 0, 0, errors.New("Bad request (malformed until)")
+		}
+	}
+
+	return h.defaultRange(from, until)
+}
+
+// defaultRange fills absent (zero) from and until
+func (h *Handler) defaultRange(from, until int64) (int64, int64, error) {
+	lookback := int64(h.config.ClickHouse.FindDefaultLookback.Value().Seconds())
+
+	if from == 0 && lookback <= 0 {
+		return 0, 0, nil
+	}
+
+	if until == 0 {
+		until = time.Now().Unix()
+	}
+
+	if from == 0 {
+		from = until - lookback
+	}
+
+	if from > until {
+		return 0, 0, errors.New("Bad request (from is greater than until)")
+	}
+
+	return from, until, nil
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	r.ParseMultipartForm(1024 * 1024)
 
-	f, err := New(h.config, r.Context(), r.FormValue("query"))
+	switch r.FormValue("format") {
+	case "pickle", "protobuf", "carbonapi_v2_pb", "json", "treejson", "":
+	case "carbonapi_v3_pb":
+		h.serveCarbonapiV3(w, r)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("Unknown format %#v", r.FormValue("format")), http.StatusBadRequest)
+		return
+	}
+
+	from, until, err := h.parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := New(h.config, r.Context(), r.FormValue("query"), from, until)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -32,7 +102,66 @@ func (h *Handler) Reply(w http.ResponseWriter, r *http.Request, f *Find) {
 	switch r.FormValue("format") {
 	case "pickle":
 		f.WritePickle(w)
-	case "protobuf":
+	case "protobuf", "carbonapi_v2_pb":
 		f.WriteProtobuf(w)
+	case "json", "treejson", "":
+		w.Header().Set("Content-Type", "application/json")
+		f.WriteJSON(w)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown format %#v", r.FormValue("format")), http.StatusBadRequest)
+	}
+}
+
+// serveCarbonapiV3 replies to carbonapi v3 MultiGlobRequest.
+// Request without body is made from query parameters
+func (h *Handler) serveCarbonapiV3(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &carbonapi_v3_pb.MultiGlobRequest{}
+	if len(body) > 0 {
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, fmt.Sprintf("Bad request (malformed MultiGlobRequest: %s)", err.Error()), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Metrics = r.Form["query"]
+
+		from, until, err := h.parseRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.StartTime, req.StopTime = from, until
+	}
+
+	from, until, err := h.defaultRange(req.StartTime, req.StopTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	response := &carbonapi_v3_pb.MultiGlobResponse{}
+
+	for _, query := range req.Metrics {
+		f, err := New(h.config, r.Context(), query, from, until)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response.Metrics = append(response.Metrics, f.GlobResponseV3())
+	}
+
+	b, err := proto.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(b)
 }
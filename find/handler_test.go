@@ -1,14 +1,19 @@
 package find
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
 	"github.com/lomik/graphite-clickhouse/config"
 )
 
@@ -71,3 +76,107 @@ func TestFind(t *testing.T) {
 		"SELECT Path FROM graphite_tree WHERE (Path LIKE 'host.%') AND (match(Path, '^host[.](.*?)[.]cpu[.]?$')) AND (Deleted = 0) GROUP BY Path",
 	)
 }
+
+func TestFindUnknownFormat(t *testing.T) {
+	cfg := config.New()
+	cfg.ClickHouse.Url = "http://localhost:1"
+
+	handler := NewHandler(cfg)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(
+		"GET",
+		"http://localhost/metrics/find/?format=png&query=host.*",
+		nil,
+	)
+
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("%d (actual) != %d (expected)", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestFindCarbonapiV3(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("'host.%'")) {
+			w.Write([]byte("host.cpu\nhost.top.\n"))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+
+	body, err := proto.Marshal(&carbonapi_v3_pb.MultiGlobRequest{
+		Metrics: []string{"host.*", "unknown.*"},
+	})
+	assert.NoError(err)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "http://localhost/metrics/find/?format=carbonapi_v3_pb", bytes.NewReader(body))
+	NewHandler(cfg).ServeHTTP(w, r)
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	var response carbonapi_v3_pb.MultiGlobResponse
+	assert.NoError(proto.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(response.Metrics, 2) {
+		assert.Equal(&carbonapi_v3_pb.GlobResponse{
+			Name: "host.*",
+			Matches: []*carbonapi_v3_pb.GlobMatch{
+				{Path: "host.cpu", IsLeaf: true},
+				{Path: "host.top", IsLeaf: false},
+			},
+		}, response.Metrics[0])
+		assert.Equal("unknown.*", response.Metrics[1].Name)
+		assert.Empty(response.Metrics[1].Matches)
+	}
+}
+
+func TestFindRange(t *testing.T) {
+	assert := assert.New(t)
+
+	requestLog := make(chan []byte, 10)
+	srv := httptest.NewServer(&clickhouseMock{requestLog: requestLog})
+	defer srv.Close()
+
+	find := func(lookback time.Duration, url string) string {
+		cfg := config.New()
+		cfg.ClickHouse.Url = srv.URL
+		cfg.ClickHouse.DateTreeTable = "graphite_series"
+		cfg.ClickHouse.DateTreeTableVersion = 2
+		cfg.ClickHouse.FindDefaultLookback = &config.Duration{Duration: lookback}
+
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		assert.Equal(http.StatusOK, w.Code, url)
+
+		select {
+		case q := <-requestLog:
+			return string(q)
+		default:
+			return ""
+		}
+	}
+
+	// date-tree-table is used for request with time range
+	q := find(0, "http://localhost/metrics/find/?format=pickle&query=host.*&from=1520056800&until=1520143200")
+	assert.Contains(q, "FROM graphite_series")
+	assert.Contains(q, "Date >='2018-03-03'")
+
+	// tree-table without range and default lookback
+	q = find(0, "http://localhost/metrics/find/?format=pickle&query=host.*")
+	assert.Contains(q, "FROM graphite_tree")
+
+	// default lookback
+	q = find(24*time.Hour, "http://localhost/metrics/find/?format=pickle&query=host.*")
+	assert.Contains(q, "FROM graphite_series")
+	assert.Contains(q, "Date >='"+time.Now().Add(-24*time.Hour).Format("2006-01-02")+"'")
+
+	w := httptest.NewRecorder()
+	NewHandler(config.New()).ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/metrics/find/?query=host.*&from=abc", nil))
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
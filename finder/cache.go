@@ -0,0 +1,107 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/helper/cache"
+)
+
+// cachedResult is result of wrapped finder with calculated Abs for every path
+type cachedResult struct {
+	list   [][]byte
+	series [][]byte
+	abs    map[string][]byte
+}
+
+func newCachedResult(f Finder) (*cachedResult, int64) {
+	r := &cachedResult{
+		list:   f.List(),
+		series: f.Series(),
+		abs:    make(map[string][]byte),
+	}
+
+	var size int64
+	for _, rows := range [][][]byte{r.list, r.series} {
+		for _, v := range rows {
+			size += int64(len(v)) + 24
+			if _, ok := r.abs[string(v)]; ok {
+				continue
+			}
+			abs := f.Abs(v)
+			r.abs[string(v)] = abs
+			size += int64(len(v)+len(abs)) + 64
+		}
+	}
+
+	return r, size
+}
+
+type CacheFinder struct {
+	wrapped Finder
+	cache   *cache.Cache  // config
+	table   string        // config
+	ttl     time.Duration // config
+	result  *cachedResult // request
+}
+
+func WrapCache(f Finder, c *cache.Cache, table string, ttl time.Duration) *CacheFinder {
+	return &CacheFinder{
+		wrapped: f,
+		cache:   c,
+		table:   table,
+		ttl:     ttl,
+	}
+}
+
+// key returns cache key with from and until rounded to ttl
+func (c *CacheFinder) key(query string, from int64, until int64) string {
+	if round := int64(c.ttl.Seconds()); round > 1 {
+		from -= from % round
+		until -= until % round
+	}
+	return fmt.Sprintf("%s;%s;%d;%d", c.table, query, from, until)
+}
+
+func (c *CacheFinder) Execute(ctx context.Context, query string, from int64, until int64) error {
+	key := c.key(query, from, until)
+
+	if v, ok := c.cache.Get(key); ok {
+		c.result = v.(*cachedResult)
+		return nil
+	}
+
+	if err := c.wrapped.Execute(ctx, query, from, until); err != nil {
+		return err
+	}
+
+	result, size := newCachedResult(c.wrapped)
+	c.cache.Set(key, result, size+int64(len(key)), c.ttl)
+	c.result = result
+
+	return nil
+}
+
+func (c *CacheFinder) List() [][]byte {
+	if c.result == nil {
+		return [][]byte{}
+	}
+	return c.result.list
+}
+
+func (c *CacheFinder) Series() [][]byte {
+	if c.result == nil {
+		return [][]byte{}
+	}
+	return c.result.series
+}
+
+func (c *CacheFinder) Abs(v []byte) []byte {
+	if c.result != nil {
+		if abs, ok := c.result.abs[string(v)]; ok {
+			return abs
+		}
+	}
+	return c.wrapped.Abs(v)
+}
@@ -0,0 +1,42 @@
+package finder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/helper/cache"
+)
+
+func TestCacheFinder(t *testing.T) {
+	assert := assert.New(t)
+
+	c := cache.New(1024 * 1024)
+
+	find := func(query string, from int64, until int64) (*MockFinder, *CacheFinder) {
+		m := NewMockFinder([][]byte{[]byte("hello.world")})
+		f := WrapCache(WrapPrefix(m, "ch"), c, "graphite_tree", time.Minute)
+
+		assert.NoError(f.Execute(context.Background(), query, from, until))
+		assert.Equal([][]byte{[]byte("hello.world")}, f.Series())
+		assert.Equal("ch.hello.world", string(f.Abs([]byte("hello.world"))))
+		return m, f
+	}
+
+	m, _ := find("ch.hello.*", 1000, 2000)
+	assert.Equal("hello.*", m.query)
+
+	// from and until are rounded to ttl
+	m, _ = find("ch.hello.*", 1010, 2010)
+	assert.Equal("", m.query)
+
+	m, _ = find("ch.hello.*", 1300, 2000)
+	assert.Equal("hello.*", m.query)
+
+	stats := c.Stats()
+	assert.Equal(uint64(1), stats.Hits)
+	assert.Equal(uint64(2), stats.Misses)
+	assert.Equal(2, stats.Items)
+}
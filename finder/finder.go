@@ -3,6 +3,7 @@ package finder
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
 
@@ -36,13 +37,22 @@ func Find(config *config.Config, ctx context.Context, query string, from int64,
 				f = WrapBlacklist(f, config.Common.Blacklist)
 			}
 
+			if config.FindCache.Cache != nil && config.FindCache.TaggedTTL.Value() > 0 {
+				f = WrapCache(f, config.FindCache.Cache, config.ClickHouse.TaggedTable, config.FindCache.TaggedTTL.Value())
+			}
+
 			return f
 		}
 
+		var table string
+		var ttl time.Duration
+
 		if from > 0 && until > 0 && config.ClickHouse.DateTreeTable != "" {
 			f = NewDateFinder(config.ClickHouse.Url, config.ClickHouse.DateTreeTable, config.ClickHouse.DateTreeTableVersion, opts)
+			table, ttl = config.ClickHouse.DateTreeTable, config.FindCache.DateTreeTTL.Value()
 		} else {
 			f = NewBase(config.ClickHouse.Url, config.ClickHouse.TreeTable, opts)
+			table, ttl = config.ClickHouse.TreeTable, config.FindCache.TreeTTL.Value()
 		}
 
 		if config.ClickHouse.ReverseTreeTable != "" {
@@ -61,6 +71,10 @@ func Find(config *config.Config, ctx context.Context, query string, from int64,
 			f = WrapBlacklist(f, config.Common.Blacklist)
 		}
 
+		if config.FindCache.Cache != nil && ttl > 0 {
+			f = WrapCache(f, config.FindCache.Cache, table, ttl)
+		}
+
 		return f
 
 	}()
@@ -70,6 +84,12 @@ func Find(config *config.Config, ctx context.Context, query string, from int64,
 		return nil, err
 	}
 
+	if config.Limits.MaxSeriesPerTarget > 0 {
+		if err := CheckLimit("max-series-per-target", len(fnd.Series()), config.Limits.MaxSeriesPerTarget); err != nil {
+			return nil, err
+		}
+	}
+
 	return fnd.(Result), err
 }
 
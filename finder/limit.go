@@ -0,0 +1,33 @@
+package finder
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// LimitError is returned if request exceeds one of configured limits
+type LimitError struct {
+	Limit string // name of limit in config
+	Value int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("request exceeds %s limit: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+// CheckLimit returns LimitError if max > 0 and value > max
+func CheckLimit(limit string, value int, max int) error {
+	if max > 0 && value > max {
+		return &LimitError{Limit: limit, Value: value, Max: max}
+	}
+	return nil
+}
+
+// HTTPStatus returns 400 for LimitError and 500 for other errors
+func HTTPStatus(err error) int {
+	if _, ok := err.(*LimitError); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -64,6 +64,22 @@ func NewTagged(url string, table string, opts clickhouse.Options) *TaggedFinder
 	}
 }
 
+// TagNameSQL returns expression of tag name in column of "tag=value" items like Tag1 or arrayJoin(Tags)
+func TagNameSQL(column string) string {
+	return fmt.Sprintf("splitByChar('=', %s)[1]", column)
+}
+
+// TagValueSQL returns expression of tag value in column of "tag=value" items. Value is everything after
+// the first '=', it may contain '=' itself. Column must be filtered by TagValueWhere with the same tag
+func TagValueSQL(column string, tag string) string {
+	return fmt.Sprintf("substring(%s, %d)", column, len(tag)+2)
+}
+
+// TagValueWhere returns condition for column of "tag=value" items to have tag with value starting with valuePrefix
+func TagValueWhere(column string, tag string, valuePrefix string) string {
+	return fmt.Sprintf("startsWith(%s, %s)", column, Q(tag+"="+valuePrefix))
+}
+
 func TaggedTermWhere1(term *TaggedTerm) string {
 	// positive expression check only in Tag1
 	// negative check in all Tags
@@ -185,30 +201,31 @@ func MakeTaggedWhere(expr []string) (string, string, error) {
 	return w.String(), prewhere, nil
 }
 
-func (t *TaggedFinder) makeWhere(query string) (string, string, error) {
+// parseSeriesByTag returns conditions of seriesByTag call
+func parseSeriesByTag(query string) ([]string, error) {
 	expr, _, err := parser.ParseExpr(query)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	validationError := fmt.Errorf("wrong seriesByTag call: %#v", query)
 
 	// check
 	if !expr.IsFunc() {
-		return "", "", validationError
+		return nil, validationError
 	}
 	if expr.Target() != "seriesByTag" {
-		return "", "", validationError
+		return nil, validationError
 	}
 
 	args := expr.Args()
 	if len(args) < 1 {
-		return "", "", validationError
+		return nil, validationError
 	}
 
 	for i := 0; i < len(args); i++ {
 		if !args[i].IsString() {
-			return "", "", validationError
+			return nil, validationError
 		}
 	}
 
@@ -221,11 +238,34 @@ func (t *TaggedFinder) makeWhere(query string) (string, string, error) {
 		conditions = append(conditions, s)
 	}
 
+	return conditions, nil
+}
+
+func (t *TaggedFinder) makeWhere(query string) (string, string, error) {
+	conditions, err := parseSeriesByTag(query)
+	if err != nil {
+		return "", "", err
+	}
+
 	return MakeTaggedWhere(conditions)
 }
 
 func (t *TaggedFinder) Execute(ctx context.Context, query string, from int64, until int64) error {
-	w, pw, err := t.makeWhere(query)
+	conditions, err := parseSeriesByTag(query)
+	if err != nil {
+		return err
+	}
+
+	return t.ExecuteExpr(ctx, conditions, from, until)
+}
+
+// ExecuteExpr finds series matched all of expressions like "tag=value", "tag!=~regexp"
+func (t *TaggedFinder) ExecuteExpr(ctx context.Context, expr []string, from int64, until int64) error {
+	if len(expr) == 0 {
+		return fmt.Errorf("empty seriesByTag expr")
+	}
+
+	w, pw, err := MakeTaggedWhere(expr)
 	if err != nil {
 		return err
 	}
@@ -274,6 +314,28 @@ func (t *TaggedFinder) Series() [][]byte {
 	return t.List()
 }
 
+// TaggedPath returns Path and Tags columns of tagged table for series name with tags. Path is
+// "name?k1=v1&k2=v2" with sorted keys, Tags are sorted "k=v" items with name as __name__ tag.
+// Abs converts Path back to graphite form
+func TaggedPath(name string, tags map[string]string) (string, []string) {
+	values := url.Values{}
+	list := make([]string, 0, len(tags)+1)
+
+	for k, v := range tags {
+		values.Set(k, v)
+		list = append(list, fmt.Sprintf("%s=%s", k, v))
+	}
+	list = append(list, fmt.Sprintf("__name__=%s", name))
+	sort.Strings(list)
+
+	path := url.PathEscape(name)
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+
+	return path, list
+}
+
 func (t *TaggedFinder) Abs(v []byte) []byte {
 	u, err := url.Parse(string(v))
 	if err != nil {
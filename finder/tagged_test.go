@@ -46,3 +46,28 @@ func TestTaggedWhere(t *testing.T) {
 		srv.Close()
 	}
 }
+
+func TestTagValueSQL(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("splitByChar('=', Tag1)[1]", TagNameSQL("Tag1"))
+
+	// '_' and '%' are not wildcards, value with '=' is not truncated
+	assert.Equal("startsWith(Tag1, 'my_tag=a%')", TagValueWhere("Tag1", "my_tag", "a%"))
+	assert.Equal("substring(Tag1, 8)", TagValueSQL("Tag1", "my_tag"))
+	assert.Equal("startsWith(arrayJoin(Tags), 'my_tag=')", TagValueWhere("arrayJoin(Tags)", "my_tag", ""))
+	assert.Equal("substring(arrayJoin(Tags), 8)", TagValueSQL("arrayJoin(Tags)", "my_tag"))
+}
+
+func TestTaggedPath(t *testing.T) {
+	assert := assert.New(t)
+
+	path, tags := TaggedPath("cpu.usage", map[string]string{"host": "a:1", "dc": "eu"})
+	assert.Equal("cpu.usage?dc=eu&host=a%3A1", path)
+	assert.Equal([]string{"__name__=cpu.usage", "dc=eu", "host=a:1"}, tags)
+	assert.Equal("cpu.usage;dc=eu;host=a:1", string(NewTagged("", "", clickhouse.Options{}).Abs([]byte(path))))
+
+	path, tags = TaggedPath("cpu", nil)
+	assert.Equal("cpu", path)
+	assert.Equal([]string{"__name__=cpu"}, tags)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/binary"
+	"expvar"
 	"flag"
 	"fmt"
 	"log"
@@ -15,11 +16,15 @@ import (
 	"github.com/lomik/graphite-clickhouse/autocomplete"
 	"github.com/lomik/graphite-clickhouse/config"
 	"github.com/lomik/graphite-clickhouse/find"
+	"github.com/lomik/graphite-clickhouse/health"
+	"github.com/lomik/graphite-clickhouse/helper/metrics"
 	"github.com/lomik/graphite-clickhouse/helper/version"
 	"github.com/lomik/graphite-clickhouse/index"
+	"github.com/lomik/graphite-clickhouse/info"
 	"github.com/lomik/graphite-clickhouse/prometheus"
 	"github.com/lomik/graphite-clickhouse/render"
 	"github.com/lomik/graphite-clickhouse/tagger"
+	"github.com/lomik/graphite-clickhouse/tags"
 	"github.com/lomik/zapwriter"
 	"go.uber.org/zap"
 
@@ -154,14 +159,80 @@ func main() {
 
 	/* CONSOLE COMMANDS end */
 
-	http.Handle("/metrics/find/", Handler(zapwriter.Default(), find.NewHandler(cfg)))
-	http.Handle("/metrics/index.json", Handler(zapwriter.Default(), index.NewHandler(cfg)))
-	http.Handle("/render/", Handler(zapwriter.Default(), render.NewHandler(cfg)))
-	http.Handle("/read", Handler(zapwriter.Default(), prometheus.NewHandler(cfg)))
-	http.Handle("/tags/autoComplete/tags", Handler(zapwriter.Default(), autocomplete.NewTags(cfg)))
-	http.Handle("/tags/autoComplete/values", Handler(zapwriter.Default(), autocomplete.NewValues(cfg)))
+	reloader := NewReloader(*configFile, cfg)
+	reloader.HandleSignals()
+
+	expvar.Publish("find_cache", expvar.Func(func() interface{} {
+		if c := reloader.Config().FindCache.Cache; c != nil {
+			return c.Stats()
+		}
+		return nil
+	}))
+
+	expvar.Publish("render_cache", expvar.Func(func() interface{} {
+		if c := reloader.Config().RenderCache.Cache; c != nil {
+			return c.Stats()
+		}
+		return nil
+	}))
+
+	handle := func(pattern string, name string, handler http.Handler) {
+		http.Handle(pattern, Handler(zapwriter.Default(), metrics.InstrumentHandler(name, handler)))
+	}
+
+	handle("/metrics/find/", "find", reloader.Handler(func(cfg *config.Config) http.Handler { return find.NewHandler(cfg) }))
+	handle("/metrics/index.json", "index", reloader.Handler(func(cfg *config.Config) http.Handler { return index.NewHandler(cfg) }))
+	handle("/render/", "render", reloader.Handler(func(cfg *config.Config) http.Handler { return render.NewHandler(cfg) }))
+	handle("/info/", "info", reloader.Handler(func(cfg *config.Config) http.Handler { return info.NewHandler(cfg) }))
+	handle("/read", "read", reloader.Handler(func(cfg *config.Config) http.Handler { return prometheus.NewHandler(cfg) }))
+	handle("/api/v1/", "api", reloader.Handler(func(cfg *config.Config) http.Handler { return prometheus.NewAPIHandler(cfg) }))
+	// background workers flushed on shutdown
+	var stop []func()
+
+	if cfg.PrometheusWrite.Enabled {
+		// write queue and uploaders are not recreated on reload
+		writeHandler := prometheus.NewWriteHandler(cfg)
+		handle("/write", "write", writeHandler)
+		stop = append(stop, writeHandler.Stop)
+	}
+	handle("/tags/autoComplete/tags", "autocomplete_tags", reloader.Handler(func(cfg *config.Config) http.Handler { return autocomplete.NewTags(cfg) }))
+	handle("/tags/autoComplete/values", "autocomplete_values", reloader.Handler(func(cfg *config.Config) http.Handler { return autocomplete.NewValues(cfg) }))
+	handle("/tags/tagSeries", "tag_series", reloader.Handler(func(cfg *config.Config) http.Handler { return tags.NewTagSeries(cfg) }))
+	handle("/tags/tagMultiSeries", "tag_multi_series", reloader.Handler(func(cfg *config.Config) http.Handler { return tags.NewTagMultiSeries(cfg) }))
+	handle("/tags/delSeries", "del_series", reloader.Handler(func(cfg *config.Config) http.Handler { return tags.NewDelSeries(cfg) }))
+	handle("/tags/findSeries", "find_series", reloader.Handler(func(cfg *config.Config) http.Handler { return tags.NewFindSeries(cfg) }))
+	tagsHandler := reloader.Handler(func(cfg *config.Config) http.Handler { return tags.NewTags(cfg) })
+	handle("/tags", "tags", tagsHandler)
+	handle("/tags/", "tags", tagsHandler)
+
+	http.Handle("/admin/reload", Handler(zapwriter.Default(), reloader))
+	http.Handle("/metrics", metrics.NewHandler())
+
+	ready := health.NewReady(reloader.Config, cfg.Common.ReadyInterval.Value())
+	if err := ready.Start(); err != nil {
+		log.Fatal(err)
+	}
+	stop = append(stop, ready.Stop)
+
+	// metrics are sent last to include shutdown
+	if cfg.Common.MetricEndpoint != "" {
+		sender := metrics.NewSender(cfg.Common.MetricEndpoint, cfg.Common.MetricPrefix, cfg.Common.MetricInterval.Value())
+		if err := sender.Start(); err != nil {
+			log.Fatal(err)
+		}
+		stop = append(stop, sender.Stop)
+	}
+	http.Handle("/ready", ready)
+	http.Handle("/health", health.NewHealth())
 
 	http.Handle("/", Handler(zapwriter.Default(), http.HandlerFunc(http.NotFound)))
 
-	log.Fatal(http.ListenAndServe(cfg.Common.Listen, nil))
+	err = serve(cfg.Common.Listen, ready, func() time.Duration {
+		return reloader.Config().Common.ShutdownDelay.Value()
+	}, func() time.Duration {
+		return reloader.Config().Common.ShutdownTimeout.Value()
+	}, stop...)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,14 @@
+package health
+
+import "net/http"
+
+// Health reports that daemon is alive
+type Health struct{}
+
+func NewHealth() *Health {
+	return &Health{}
+}
+
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK\n"))
+}
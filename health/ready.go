@@ -0,0 +1,178 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lomik/stop"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+// TableStatus is result of table check
+type TableStatus struct {
+	Kind  string `json:"kind"`
+	Table string `json:"table"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Status is result of all checks
+type Status struct {
+	Ready      bool           `json:"ready"`
+	Shutdown   bool           `json:"shutdown"`
+	Checked    int64          `json:"checked"`
+	ClickHouse TableStatus    `json:"clickhouse"`
+	Tables     []*TableStatus `json:"tables"`
+}
+
+// columns required by queries for each kind of table
+var requiredColumns = map[string][]string{
+	"data-table":         {"Path", "Value", "Time", "Date", "Timestamp"},
+	"tree-table":         {"Level", "Path", "Deleted"},
+	"date-tree-table":    {"Date", "Level", "Path"},
+	"reverse-tree-table": {"Level", "Path", "Deleted"},
+	"tagged-table":       {"Date", "Tag1", "Path", "Tags", "Deleted", "Version"},
+	"tag-table":          {"Prefix", "Level", "Path", "Version", "Tag1", "Tags", "IsLeaf"},
+}
+
+// Ready periodically checks clickhouse and tables from config and reports readiness of daemon to serve requests
+type Ready struct {
+	stop.Struct
+	shutdown int32
+	config   func() *config.Config
+	interval time.Duration
+	status   atomic.Value // *Status
+}
+
+func NewReady(config func() *config.Config, interval time.Duration) *Ready {
+	return &Ready{
+		config:   config,
+		interval: interval,
+	}
+}
+
+// Shutdown marks daemon as not ready. New requests should be sent to another node
+func (h *Ready) Shutdown() {
+	atomic.StoreInt32(&h.shutdown, 1)
+}
+
+func checkTable(ctx context.Context, cfg *config.Config, opts clickhouse.Options, s *TableStatus) {
+	body, err := clickhouse.Query(ctx, cfg.ClickHouse.Url, fmt.Sprintf("DESCRIBE TABLE %s", s.Table), s.Table, opts)
+	if err != nil {
+		s.Error = err.Error()
+		return
+	}
+
+	columns := make(map[string]bool)
+	for _, row := range strings.Split(string(body), "\n") {
+		columns[strings.SplitN(row, "\t", 2)[0]] = true
+	}
+
+	missing := make([]string, 0)
+	for _, c := range requiredColumns[s.Kind] {
+		if !columns[c] {
+			missing = append(missing, c)
+		}
+	}
+
+	if len(missing) > 0 {
+		s.Error = fmt.Sprintf("missing columns: %s", strings.Join(missing, ", "))
+		return
+	}
+
+	s.OK = true
+}
+
+// Check checks connectivity to clickhouse and schema of configured tables
+func (h *Ready) Check(ctx context.Context) *Status {
+	cfg := h.config()
+	opts := clickhouse.Options{
+		Timeout:        h.interval,
+		ConnectTimeout: cfg.ClickHouse.ConnectTimeout.Value(),
+	}
+
+	status := &Status{
+		Checked:    time.Now().Unix(),
+		ClickHouse: TableStatus{Kind: "clickhouse"},
+		Tables:     make([]*TableStatus, 0),
+	}
+
+	if _, err := clickhouse.Query(ctx, cfg.ClickHouse.Url, "SELECT 1", "", opts); err != nil {
+		status.ClickHouse.Error = err.Error()
+		return status
+	}
+	status.ClickHouse.OK = true
+
+	tables := []*TableStatus{
+		{Kind: "data-table", Table: cfg.ClickHouse.DataTable},
+		{Kind: "tree-table", Table: cfg.ClickHouse.TreeTable},
+		{Kind: "date-tree-table", Table: cfg.ClickHouse.DateTreeTable},
+		{Kind: "reverse-tree-table", Table: cfg.ClickHouse.ReverseTreeTable},
+		{Kind: "tagged-table", Table: cfg.ClickHouse.TaggedTable},
+		{Kind: "tag-table", Table: cfg.ClickHouse.TagTable},
+	}
+	for _, t := range cfg.DataTable {
+		tables = append(tables, &TableStatus{Kind: "data-table", Table: t.Table})
+	}
+
+	status.Ready = true
+	for _, t := range tables {
+		if t.Table == "" {
+			continue
+		}
+		checkTable(ctx, cfg, opts, t)
+		status.Tables = append(status.Tables, t)
+		status.Ready = status.Ready && t.OK
+	}
+
+	return status
+}
+
+// Start runs checks every interval
+func (h *Ready) Start() error {
+	return h.StartFunc(func() error {
+		h.Go(func(exit chan struct{}) {
+			ticker := time.NewTicker(h.interval)
+			defer ticker.Stop()
+
+			for {
+				h.status.Store(h.Check(context.Background()))
+
+				select {
+				case <-exit:
+					return
+				case <-ticker.C:
+				}
+			}
+		})
+		return nil
+	})
+}
+
+func (h *Ready) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status, _ := h.status.Load().(*Status)
+	if status == nil {
+		status = &Status{}
+	}
+
+	// copy, stored status is shared between requests
+	result := *status
+	result.Shutdown = atomic.LoadInt32(&h.shutdown) != 0
+	if result.Shutdown {
+		result.Ready = false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+func TestReady(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		switch {
+		case bytes.Equal(query, []byte("DESCRIBE TABLE graphite")):
+			return []byte("Path\tString\nValue\tFloat64\nTime\tUInt32\nDate\tDate\nTimestamp\tUInt32\n")
+		case bytes.Equal(query, []byte("DESCRIBE TABLE graphite_tree")):
+			return []byte("Date\tDate\nLevel\tUInt32\nPath\tString\n")
+		case bytes.Equal(query, []byte("DESCRIBE TABLE graphite_tagged")):
+			return []byte("Date\tDate\nTag1\tString\nPath\tString\nTags\tArray(String)\nVersion\tUInt32\n")
+		}
+		return []byte("1\n")
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+
+	h := NewReady(func() *config.Config { return cfg }, time.Second)
+
+	ready := func() (int, *Status) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+
+		var status Status
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &status))
+		return w.Code, &status
+	}
+
+	// not checked yet
+	code, _ := ready()
+	assert.Equal(503, code)
+
+	h.status.Store(h.Check(context.Background()))
+	code, status := ready()
+	assert.Equal(503, code)
+	assert.True(status.ClickHouse.OK)
+	assert.Equal([]*TableStatus{
+		{Kind: "data-table", Table: "graphite", OK: true},
+		{Kind: "tree-table", Table: "graphite_tree", Error: "missing columns: Deleted"},
+	}, status.Tables)
+
+	// tagged series are marked deleted by delSeries
+	cfg.ClickHouse.TreeTable = ""
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+	h.status.Store(h.Check(context.Background()))
+	code, status = ready()
+	assert.Equal(503, code)
+	assert.Equal(&TableStatus{Kind: "tagged-table", Table: "graphite_tagged", Error: "missing columns: Deleted"}, status.Tables[1])
+
+	cfg.ClickHouse.TaggedTable = ""
+	h.status.Store(h.Check(context.Background()))
+	code, status = ready()
+	assert.Equal(200, code)
+	assert.True(status.Ready)
+
+	h.Shutdown()
+	code, status = ready()
+	assert.Equal(503, code)
+	assert.True(status.Shutdown)
+}
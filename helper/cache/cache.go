@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats of cache usage
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Items     int    `json:"items"`
+	Size      int64  `json:"size"`
+	MaxSize   int64  `json:"max_size"`
+}
+
+type item struct {
+	key    string
+	value  interface{}
+	size   int64
+	expire time.Time
+}
+
+// Cache is LRU cache with expiration of items bounded by total size of items
+type Cache struct {
+	sync.Mutex
+	maxSize int64
+	items   map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	stats   Stats
+}
+
+// New creates cache. maxSize is total size of items in bytes
+func New(maxSize int64) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		stats:   Stats{MaxSize: maxSize},
+	}
+}
+
+func (c *Cache) remove(e *list.Element) {
+	it := e.Value.(*item)
+	c.lru.Remove(e)
+	delete(c.items, it.key)
+	c.stats.Size -= it.size
+}
+
+// Get returns not expired value for key
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	it := e.Value.(*item)
+	if time.Now().After(it.expire) {
+		c.remove(e)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	c.stats.Hits++
+	return it.value, true
+}
+
+// Set stores value with approximate size in bytes for ttl.
+// Least recently used items are evicted if total size exceeds maxSize
+func (c *Cache) Set(key string, value interface{}, size int64, ttl time.Duration) {
+	if size > c.maxSize {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+
+	c.items[key] = c.lru.PushFront(&item{
+		key:    key,
+		value:  value,
+		size:   size,
+		expire: time.Now().Add(ttl),
+	})
+	c.stats.Size += size
+
+	for c.stats.Size > c.maxSize {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns copy of cache statistics
+func (c *Cache) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+
+	s := c.stats
+	s.Items = len(c.items)
+	return s
+}
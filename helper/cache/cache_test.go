@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+
+	c := New(100)
+
+	c.Set("a", "A", 40, time.Minute)
+	c.Set("b", "B", 40, time.Minute)
+
+	v, ok := c.Get("a")
+	assert.True(ok)
+	assert.Equal("A", v)
+
+	// "b" is least recently used
+	c.Set("c", "C", 40, time.Minute)
+
+	_, ok = c.Get("b")
+	assert.False(ok)
+	_, ok = c.Get("c")
+	assert.True(ok)
+
+	// too large item is not stored
+	c.Set("d", "D", 101, time.Minute)
+	_, ok = c.Get("d")
+	assert.False(ok)
+
+	// expired
+	c.Set("e", "E", 10, -time.Second)
+	_, ok = c.Get("e")
+	assert.False(ok)
+
+	assert.Equal(Stats{
+		Hits:      2,
+		Misses:    3,
+		Evictions: 1,
+		Items:     2,
+		Size:      80,
+		MaxSize:   100,
+	}, c.Stats())
+}
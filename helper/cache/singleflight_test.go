@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var g Group
+	var calls int32
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, _ := g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-start
+				return "value", nil
+			})
+			assert.NoError(err)
+			assert.Equal("value", v)
+		}()
+	}
+
+	// let all goroutines join to the first call
+	time.Sleep(100 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	assert.Empty(g.m)
+}
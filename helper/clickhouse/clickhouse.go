@@ -8,12 +8,12 @@ import (
 	"io"
 	"io/ioutil"
 	"math/rand"
-	"net"
 	"net/http"
-	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/lomik/graphite-clickhouse/helper/metrics"
 	"github.com/lomik/graphite-clickhouse/helper/version"
 	"github.com/lomik/zapwriter"
 
@@ -34,22 +34,43 @@ type loggedReader struct {
 	logger   *zap.Logger
 	start    time.Time
 	finished bool
+	replica  *replica // running query is counted in replica load until Close
+	closed   bool
+	table    string
+	bytes    uint64
+	ctx      context.Context
+	watcher  *queryWatcher
+}
+
+func (r *loggedReader) finish() {
+	r.finished = true
+	if r.ctx.Err() == nil {
+		// query is not canceled, don't kill it
+		r.watcher.finish()
+	}
+	d := time.Since(r.start)
+	r.logger.Info("query", zap.Duration("time", d))
+	metrics.NewHistogram("query_duration_seconds", "table", r.table).ObserveDuration(d)
+	metrics.NewCounter("query_bytes_total", "table", r.table).Add(r.bytes)
 }
 
 func (r *loggedReader) Read(p []byte) (int, error) {
 	n, err := r.reader.Read(p)
+	r.bytes += uint64(n)
 	if err != nil && !r.finished {
-		r.finished = true
-		r.logger.Info("query", zap.Duration("time", time.Since(r.start)))
+		r.finish()
 	}
 	return n, err
 }
 
 func (r *loggedReader) Close() error {
 	err := r.reader.Close()
+	if !r.closed {
+		r.closed = true
+		atomic.AddInt32(&r.replica.inflight, -1)
+	}
 	if !r.finished {
-		r.finished = true
-		r.logger.Info("query", zap.Duration("time", time.Since(r.start)))
+		r.finish()
 	}
 	return err
 }
@@ -74,18 +95,18 @@ func Query(ctx context.Context, dsn string, query string, table string, opts Opt
 }
 
 func Post(ctx context.Context, dsn string, query string, table string, postBody io.Reader, opts Options) ([]byte, error) {
-	return do(ctx, dsn, query, table, postBody, false, opts)
+	return do(ctx, dsn, query, table, postBody, nil, false, opts)
 }
 
 func PostGzip(ctx context.Context, dsn string, query string, table string, postBody io.Reader, opts Options) ([]byte, error) {
-	return do(ctx, dsn, query, table, postBody, true, opts)
+	return do(ctx, dsn, query, table, postBody, nil, true, opts)
 }
 
 func Reader(ctx context.Context, dsn string, query string, table string, opts Options) (io.ReadCloser, error) {
-	return reader(ctx, dsn, query, table, nil, false, opts)
+	return reader(ctx, dsn, query, table, nil, nil, false, opts)
 }
 
-func reader(ctx context.Context, dsn string, query string, table string, postBody io.Reader, gzip bool, opts Options) (bodyReader io.ReadCloser, err error) {
+func reader(ctx context.Context, dsn string, query string, table string, postBody io.Reader, extData *ExternalData, gzip bool, opts Options) (bodyReader io.ReadCloser, err error) {
 	start := time.Now()
 
 	var requestID string
@@ -103,10 +124,11 @@ func reader(ctx context.Context, dsn string, query string, table string, postBod
 		// fmt.Println(time.Since(start), formatSQL(queryForLogger))
 		if err != nil {
 			logger.Error("query", zap.Error(err), zap.Duration("time", time.Since(start)))
+			metrics.NewCounter("query_errors_total", "table", table).Inc()
 		}
 	}()
 
-	p, err := url.Parse(dsn)
+	c, err := getCluster(dsn, opts.ConnectTimeout)
 	if err != nil {
 		return
 	}
@@ -115,63 +137,111 @@ func reader(ctx context.Context, dsn string, query string, table string, postBod
 	binary.LittleEndian.PutUint64(b[:], rand.Uint64())
 	queryID := fmt.Sprintf("%x", b)
 
-	q := p.Query()
-	q.Set("query_id", fmt.Sprintf("%s::%s", requestID, queryID))
-	p.RawQuery = q.Encode()
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: c.transport,
+	}
 
-	if postBody != nil {
+	retry := isIdempotent(query, postBody != nil)
+	tried := make(map[*replica]bool)
+
+	for {
+		r := c.pick(tried)
+		tried[r] = true
+
+		p := *r.url
 		q := p.Query()
-		q.Set("query", query)
+		fullQueryID := fmt.Sprintf("%s::%s", requestID, queryID)
+		q.Set("query_id", fullQueryID)
+
+		body := postBody
+		var contentType string
+		if extData != nil {
+			q.Set("query", query)
+			extData.setParams(q)
+			body, contentType = extData.multipart()
+		} else if postBody != nil {
+			q.Set("query", query)
+		} else {
+			body = strings.NewReader(query)
+		}
 		p.RawQuery = q.Encode()
-	} else {
-		postBody = strings.NewReader(query)
-	}
 
-	url := p.String()
+		var req *http.Request
+		req, err = http.NewRequest("POST", p.String(), body)
+		if err != nil {
+			return
+		}
+		req = req.WithContext(ctx)
 
-	req, err := http.NewRequest("POST", url, postBody)
-	if err != nil {
-		return
-	}
+		req.Header.Add("User-Agent", fmt.Sprintf("graphite-clickhouse/%s (table:%s)", version.Version, table))
 
-	req.Header.Add("User-Agent", fmt.Sprintf("graphite-clickhouse/%s (table:%s)", version.Version, table))
+		if gzip {
+			req.Header.Add("Content-Encoding", "gzip")
+		}
 
-	if gzip {
-		req.Header.Add("Content-Encoding", "gzip")
-	}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
 
-	client := &http.Client{
-		Timeout: opts.Timeout,
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: opts.ConnectTimeout,
-			}).Dial,
-			DisableKeepAlives: true,
-		},
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return
-	}
+		atomic.AddInt32(&r.inflight, 1)
 
-	if resp.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		err = fmt.Errorf("clickhouse response status %d: %s", resp.StatusCode, string(body))
-		return
-	}
+		// kills query on replica if ctx is canceled before query is finished
+		watcher := c.watch(ctx, r, fullQueryID, logger)
 
-	bodyReader = &loggedReader{
-		reader: resp.Body,
-		logger: logger,
-		start:  start,
-	}
+		var resp *http.Response
+		resp, err = client.Do(req)
+
+		if err == nil && resp.StatusCode != 200 {
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("clickhouse response status %d: %s", resp.StatusCode, string(respBody))
 
-	return
+			if resp.StatusCode < 500 {
+				// query error, replica is ok
+				watcher.finish()
+				atomic.AddInt32(&r.inflight, -1)
+				r.success()
+				return
+			}
+		}
+
+		if err != nil {
+			atomic.AddInt32(&r.inflight, -1)
+
+			if ctx.Err() != nil {
+				// query is canceled, replica is ok. watcher kills query
+				return
+			}
+
+			watcher.finish()
+			r.fail()
+
+			if retry && len(tried) < len(c.replicas) {
+				logger.Warn("query failed, retry on another replica", zap.String("replica", r.url.Host), zap.Error(err))
+				continue
+			}
+			return
+		}
+
+		r.success()
+
+		bodyReader = &loggedReader{
+			reader:  resp.Body,
+			logger:  logger,
+			start:   start,
+			replica: r,
+			table:   table,
+			ctx:     ctx,
+			watcher: watcher,
+		}
+
+		return
+	}
 }
 
-func do(ctx context.Context, dsn string, query string, table string, postBody io.Reader, gzip bool, opts Options) ([]byte, error) {
-	bodyReader, err := reader(ctx, dsn, query, table, postBody, gzip, opts)
+func do(ctx context.Context, dsn string, query string, table string, postBody io.Reader, extData *ExternalData, gzip bool, opts Options) ([]byte, error) {
+	bodyReader, err := reader(ctx, dsn, query, table, postBody, extData, gzip, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+// ExternalData is temporary table sent with query in multipart body. Table is available in query by Name.
+// See https://clickhouse.yandex/docs/en/table_engines/external_data/
+type ExternalData struct {
+	Name      string
+	Structure string // columns like "Path String"
+	body      *bytes.Buffer
+}
+
+func NewExternalData(name string, structure string) *ExternalData {
+	return &ExternalData{
+		Name:      name,
+		Structure: structure,
+		body:      new(bytes.Buffer),
+	}
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`)
+
+// AppendRow adds row with values of columns in TabSeparated format
+func (d *ExternalData) AppendRow(values ...string) {
+	for i, v := range values {
+		if i > 0 {
+			d.body.WriteByte('\t')
+		}
+		d.body.WriteString(tsvEscaper.Replace(v))
+	}
+	d.body.WriteByte('\n')
+}
+
+// Len returns size of table in bytes
+func (d *ExternalData) Len() int {
+	return d.body.Len()
+}
+
+// setParams sets structure and format of table in GET-parameters
+func (d *ExternalData) setParams(q map[string][]string) {
+	q[d.Name+"_structure"] = []string{d.Structure}
+	q[d.Name+"_format"] = []string{"TabSeparated"}
+}
+
+// multipart returns request body with table and its content type
+func (d *ExternalData) multipart() (io.Reader, string) {
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+
+	part, _ := w.CreateFormFile(d.Name, d.Name)
+	part.Write(d.body.Bytes())
+	w.Close()
+
+	return buf, w.FormDataContentType()
+}
+
+// ReaderWithExternalData executes query with external data table
+func ReaderWithExternalData(ctx context.Context, dsn string, query string, table string, data *ExternalData, opts Options) (io.ReadCloser, error) {
+	return reader(ctx, dsn, query, table, nil, data, false, opts)
+}
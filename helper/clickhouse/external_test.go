@@ -0,0 +1,47 @@
+package clickhouse
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderWithExternalData(t *testing.T) {
+	assert := assert.New(t)
+
+	var query, structure, format, table string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		structure = r.URL.Query().Get("_data_structure")
+		format = r.URL.Query().Get("_data_format")
+
+		file, _, err := r.FormFile("_data")
+		if err == nil {
+			body, _ := ioutil.ReadAll(file)
+			table = string(body)
+		}
+
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	data := NewExternalData("_data", "Path String")
+	data.AppendRow("a.b.c")
+	data.AppendRow("tab\there")
+
+	body, err := ReaderWithExternalData(context.Background(), srv.URL, "SELECT 1 WHERE Path IN _data", "graphite", data, Options{})
+	assert.NoError(err)
+	if body != nil {
+		body.Close()
+	}
+
+	assert.Equal("SELECT 1 WHERE Path IN _data", query)
+	assert.Equal("Path String", structure)
+	assert.Equal("TabSeparated", format)
+	assert.Equal("a.b.c\ntab\\there\n", table)
+}
@@ -0,0 +1,93 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/helper/metrics"
+)
+
+// KillQueryTimeout is timeout of KILL QUERY request
+var KillQueryTimeout = 5 * time.Second
+
+var killedQueries = metrics.NewCounter("queries_killed_total")
+
+// queryWatcher kills query if context is canceled before query is finished
+type queryWatcher struct {
+	done chan struct{}
+	once sync.Once
+}
+
+// finish stops watching of successfully finished query
+func (w *queryWatcher) finish() {
+	w.once.Do(func() { close(w.done) })
+}
+
+func (c *cluster) watch(ctx context.Context, r *replica, queryID string, logger *zap.Logger) *queryWatcher {
+	w := &queryWatcher{done: make(chan struct{})}
+
+	go func() {
+		select {
+		case <-w.done:
+			return
+		case <-ctx.Done():
+		}
+
+		killed, err := c.killQuery(r, queryID)
+		if err != nil {
+			logger.Warn("kill query failed", zap.String("query_id", queryID), zap.Error(err))
+			return
+		}
+
+		killedQueries.Add(uint64(killed))
+		logger.Info("query canceled", zap.String("query_id", queryID), zap.Int("killed", killed))
+	}()
+
+	return w
+}
+
+// killQuery executes KILL QUERY on replica. Returns number of killed queries
+func (c *cluster) killQuery(r *replica, queryID string) (int, error) {
+	p := *r.url
+	q := p.Query()
+	q.Del("query_id")
+	p.RawQuery = q.Encode()
+
+	query := fmt.Sprintf("KILL QUERY WHERE query_id = '%s' ASYNC FORMAT TabSeparated", Escape(queryID))
+
+	client := &http.Client{
+		Timeout:   KillQueryTimeout,
+		Transport: c.transport,
+	}
+
+	resp, err := client.Post(p.String(), "text/plain", strings.NewReader(query))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("clickhouse response status %d: %s", resp.StatusCode, string(body))
+	}
+
+	killed := 0
+	for _, row := range strings.Split(string(body), "\n") {
+		if row != "" {
+			killed++
+		}
+	}
+
+	return killed, nil
+}
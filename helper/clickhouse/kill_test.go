@@ -0,0 +1,50 @@
+package clickhouse
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKillQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	queryIDs := make(chan string, 1)
+	kills := make(chan string, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.HasPrefix(string(body), "KILL QUERY") {
+			kills <- string(body)
+			w.Write([]byte("waiting\t" + r.URL.Query().Get("query_id") + "\n"))
+			return
+		}
+
+		queryIDs <- r.URL.Query().Get("query_id")
+		// long running query
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), "requestID", "req"), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := Query(ctx, srv.URL, "SELECT sleep(10)", "test", Options{Timeout: time.Minute, ConnectTimeout: time.Second})
+	assert.Error(err)
+
+	queryID := <-queryIDs
+	assert.True(strings.HasPrefix(queryID, "req::"))
+
+	select {
+	case kill := <-kills:
+		assert.Equal("KILL QUERY WHERE query_id = '"+queryID+"' ASYNC FORMAT TabSeparated", kill)
+	case <-time.After(time.Second):
+		t.Fatal("query is not killed")
+	}
+}
@@ -0,0 +1,154 @@
+package clickhouse
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaFailTimeout is time while failed replica is not selected for queries if healthy replicas exist
+var ReplicaFailTimeout = 10 * time.Second
+
+// MaxIdleConnsPerReplica is size of keep-alive connections pool for each replica
+var MaxIdleConnsPerReplica = 100
+
+type replica struct {
+	url      *url.URL
+	inflight int32 // number of running queries
+	failedAt int64 // unix nano time of last connection error or 5xx response, 0 - healthy
+}
+
+func (r *replica) healthy(now int64) bool {
+	failedAt := atomic.LoadInt64(&r.failedAt)
+	return failedAt == 0 || now-failedAt > int64(ReplicaFailTimeout)
+}
+
+func (r *replica) fail() {
+	atomic.StoreInt64(&r.failedAt, time.Now().UnixNano())
+}
+
+func (r *replica) success() {
+	atomic.StoreInt64(&r.failedAt, 0)
+}
+
+// cluster is list of replicas from one dsn with shared keep-alive transport
+type cluster struct {
+	replicas  []*replica
+	next      uint32 // round-robin counter
+	transport *http.Transport
+}
+
+type clusterKey struct {
+	dsn            string
+	connectTimeout time.Duration
+}
+
+var clusters = struct {
+	sync.Mutex
+	m map[clusterKey]*cluster
+}{m: make(map[clusterKey]*cluster)}
+
+// SplitDSN splits dsn with comma separated replica urls like "http://ch1:8123,http://ch2:8123".
+// Commas inside url (for example in GET-parameters) are kept
+func SplitDSN(dsn string) []string {
+	parts := strings.Split(dsn, ",")
+	result := make([]string, 0, len(parts))
+
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if len(result) > 0 && !strings.HasPrefix(p, "http://") && !strings.HasPrefix(p, "https://") {
+			result[len(result)-1] += "," + p
+			continue
+		}
+		result = append(result, p)
+	}
+
+	return result
+}
+
+func newCluster(dsn string, connectTimeout time.Duration) (*cluster, error) {
+	c := &cluster{
+		transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   connectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			MaxIdleConnsPerHost: MaxIdleConnsPerReplica,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	for _, u := range SplitDSN(dsn) {
+		p, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		c.replicas = append(c.replicas, &replica{url: p})
+	}
+
+	return c, nil
+}
+
+// getCluster returns shared cluster for dsn
+func getCluster(dsn string, connectTimeout time.Duration) (*cluster, error) {
+	key := clusterKey{dsn: dsn, connectTimeout: connectTimeout}
+
+	clusters.Lock()
+	defer clusters.Unlock()
+
+	if c, ok := clusters.m[key]; ok {
+		return c, nil
+	}
+
+	c, err := newCluster(dsn, connectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters.m[key] = c
+	return c, nil
+}
+
+// pick returns least loaded healthy replica not from exclude list.
+// Replicas with same load are selected in round-robin order.
+// Returns nil if all replicas are excluded
+func (c *cluster) pick(exclude map[*replica]bool) *replica {
+	now := time.Now().UnixNano()
+	start := int(atomic.AddUint32(&c.next, 1))
+
+	var best *replica
+	var bestHealthy bool
+	var bestInflight int32
+
+	for i := 0; i < len(c.replicas); i++ {
+		r := c.replicas[(start+i)%len(c.replicas)]
+		if exclude[r] {
+			continue
+		}
+
+		healthy := r.healthy(now)
+		inflight := atomic.LoadInt32(&r.inflight)
+
+		if best == nil ||
+			(healthy && !bestHealthy) ||
+			(healthy == bestHealthy && inflight < bestInflight) {
+			best, bestHealthy, bestInflight = r, healthy, inflight
+		}
+	}
+
+	return best
+}
+
+// isIdempotent returns true if query can be retried on another replica
+func isIdempotent(query string, postBody bool) bool {
+	if postBody {
+		return false
+	}
+	q := strings.ToUpper(strings.TrimSpace(query))
+	return strings.HasPrefix(q, "SELECT") || strings.HasPrefix(q, "WITH") || strings.HasPrefix(q, "SHOW")
+}
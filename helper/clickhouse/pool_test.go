@@ -0,0 +1,87 @@
+package clickhouse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitDSN(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"http://localhost:8123"}, SplitDSN("http://localhost:8123"))
+	assert.Equal(
+		[]string{"http://ch1:8123/?user=a", "https://ch2:8123"},
+		SplitDSN("http://ch1:8123/?user=a, https://ch2:8123"),
+	)
+	assert.Equal(
+		[]string{"http://ch1:8123/?settings=a,b", "http://ch2:8123"},
+		SplitDSN("http://ch1:8123/?settings=a,b,http://ch2:8123"),
+	)
+}
+
+func TestReplicaFailover(t *testing.T) {
+	assert := assert.New(t)
+
+	var badCount, goodCount int32
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badCount, 1)
+		http.Error(w, "replica is down", http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodCount, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer good.Close()
+
+	dsn := bad.URL + "," + good.URL
+	opts := Options{Timeout: time.Second, ConnectTimeout: time.Second}
+
+	// SELECT is retried on another replica
+	for i := 0; i < 4; i++ {
+		body, err := Query(context.Background(), dsn, "SELECT 1", "test", opts)
+		assert.NoError(err)
+		assert.Equal("ok", string(body))
+	}
+
+	// failed replica is not selected while healthy one exists
+	assert.True(atomic.LoadInt32(&badCount) <= 1)
+	assert.Equal(int32(4), atomic.LoadInt32(&goodCount))
+
+	c, err := getCluster(dsn, opts.ConnectTimeout)
+	assert.NoError(err)
+	for _, r := range c.replicas {
+		assert.Equal(int32(0), atomic.LoadInt32(&r.inflight))
+	}
+
+	// INSERT is not retried
+	_, err = Post(context.Background(), bad.URL, "INSERT INTO test FORMAT RowBinary", "test", strings.NewReader("data"), opts)
+	assert.Error(err)
+}
+
+func TestReplicaLeastLoaded(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := newCluster("http://ch1:8123,http://ch2:8123,http://ch3:8123", time.Second)
+	assert.NoError(err)
+
+	c.replicas[0].inflight = 2
+	c.replicas[1].inflight = 1
+	c.replicas[2].inflight = 3
+	assert.Equal(c.replicas[1], c.pick(nil))
+
+	c.replicas[1].fail()
+	assert.Equal(c.replicas[0], c.pick(nil))
+
+	assert.Equal(c.replicas[2], c.pick(map[*replica]bool{c.replicas[0]: true, c.replicas[1]: true}))
+	assert.Nil(c.pick(map[*replica]bool{c.replicas[0]: true, c.replicas[1]: true, c.replicas[2]: true}))
+}
@@ -13,7 +13,8 @@ type TestRequest struct {
 
 type TestHandler struct {
 	sync.Mutex
-	request []TestRequest
+	request  []TestRequest
+	response func(query []byte) []byte
 }
 
 type TestServer struct {
@@ -33,7 +34,12 @@ func (h *TestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.request = make([]TestRequest, 0)
 	}
 	h.request = append(h.request, req)
+	response := h.response
 	h.Unlock()
+
+	if response != nil {
+		w.Write(response(body))
+	}
 }
 
 func NewTestServer() *TestServer {
@@ -52,3 +58,11 @@ func (srv *TestServer) Requests() []TestRequest {
 
 	return srv.handler.request
 }
+
+// SetResponse sets function returns response body for query
+func (srv *TestServer) SetResponse(response func(query []byte) []byte) {
+	srv.handler.Lock()
+	defer srv.handler.Unlock()
+
+	srv.handler.response = response
+}
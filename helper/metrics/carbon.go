@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lomik/stop"
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+)
+
+var graphiteReplacer = strings.NewReplacer(".", "_", " ", "_", "/", "_")
+
+// WriteGraphite writes all metrics of registry in graphite plaintext format.
+// Path is prefix.name.labelValue1.labelValue2[.sum|.count], histogram buckets are not written
+func (r *Registry) WriteGraphite(w io.Writer, prefix string, now time.Time) error {
+	b := bufio.NewWriter(w)
+	ts := now.Unix()
+
+	for _, e := range r.sorted() {
+		parts := []string{prefix, e.name}
+		for i := 1; i < len(e.labels); i += 2 {
+			parts = append(parts, graphiteReplacer.Replace(e.labels[i]))
+		}
+		path := strings.Join(parts, ".")
+
+		e.metric.each(func(suffix string, extraLabels []string, value float64) {
+			switch suffix {
+			case "":
+				fmt.Fprintf(b, "%s %s %d\n", path, formatFloat(value), ts)
+			case "_sum", "_count":
+				fmt.Fprintf(b, "%s.%s %s %d\n", path, suffix[1:], formatFloat(value), ts)
+			}
+		})
+	}
+
+	return b.Flush()
+}
+
+// MetricPrefix replaces {host} in prefix with hostname
+func MetricPrefix(prefix string) string {
+	if !strings.Contains(prefix, "{host}") {
+		return prefix
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return strings.Replace(prefix, "{host}", graphiteReplacer.Replace(hostname), -1)
+}
+
+// Sender periodically sends metrics of Default registry to carbon
+type Sender struct {
+	stop.Struct
+	endpoint string // host:port of carbon plaintext receiver
+	prefix   string
+	interval time.Duration
+}
+
+func NewSender(endpoint string, prefix string, interval time.Duration) *Sender {
+	return &Sender{
+		endpoint: endpoint,
+		prefix:   MetricPrefix(prefix),
+		interval: interval,
+	}
+}
+
+func (s *Sender) send(now time.Time) error {
+	network, address := "tcp", s.endpoint
+	if i := strings.Index(address, "://"); i >= 0 {
+		network, address = address[:i], address[i+3:]
+	}
+
+	conn, err := net.DialTimeout(network, address, time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(now.Add(s.interval))
+
+	return Default.WriteGraphite(conn, s.prefix, now)
+}
+
+// Start starts sending loop
+func (s *Sender) Start() error {
+	return s.StartFunc(func() error {
+		s.Go(func(exit chan struct{}) {
+			logger := zapwriter.Logger("metrics")
+
+			ticker := time.NewTicker(s.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-exit:
+					// last values before stop
+					if err := s.send(time.Now()); err != nil {
+						logger.Error("send failed", zap.String("endpoint", s.endpoint), zap.Error(err))
+					}
+					return
+				case now := <-ticker.C:
+					if err := s.send(now); err != nil {
+						logger.Error("send failed", zap.String("endpoint", s.endpoint), zap.Error(err))
+					}
+				}
+			}
+		})
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// InstrumentHandler counts requests, server errors and request durations of handler
+func InstrumentHandler(name string, handler http.Handler) http.Handler {
+	requests := NewCounter("requests_total", "handler", name)
+	errors := NewCounter("request_errors_total", "handler", name)
+	duration := NewHistogram("request_duration_seconds", "handler", name)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		writer := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		handler.ServeHTTP(writer, r)
+
+		requests.Inc()
+		if writer.status >= 500 {
+			errors.Inc()
+		}
+		duration.Since(start)
+	})
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are upper bounds of histogram buckets in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type metric interface {
+	// each calls cb for every exported value of metric
+	each(cb func(suffix string, extraLabels []string, value float64))
+	kind() string
+}
+
+// Counter is monotonically increasing value
+type Counter struct {
+	value uint64
+}
+
+// Add increments counter by n
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Inc increments counter by 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns current counter value
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) kind() string {
+	return "counter"
+}
+
+func (c *Counter) each(cb func(suffix string, extraLabels []string, value float64)) {
+	cb("", nil, float64(c.Value()))
+}
+
+// Histogram counts observed values in buckets
+type Histogram struct {
+	sync.Mutex
+	buckets []float64
+	counts  []uint64 // counts[i] is number of values <= buckets[i], last is +Inf
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Observe adds value to histogram
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.Unlock()
+}
+
+// ObserveDuration adds duration in seconds to histogram
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// Since adds duration since start in seconds to histogram
+func (h *Histogram) Since(start time.Time) {
+	h.ObserveDuration(time.Since(start))
+}
+
+// Count returns number of observed values
+func (h *Histogram) Count() uint64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.count
+}
+
+func (h *Histogram) kind() string {
+	return "histogram"
+}
+
+func (h *Histogram) each(cb func(suffix string, extraLabels []string, value float64)) {
+	h.Lock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	sum, count := h.sum, h.count
+	h.Unlock()
+
+	var cumulative uint64
+	for i, b := range h.buckets {
+		cumulative += counts[i]
+		cb("_bucket", []string{"le", formatFloat(b)}, float64(cumulative))
+	}
+	cb("_bucket", []string{"le", "+Inf"}, float64(count))
+	cb("_sum", nil, sum)
+	cb("_count", nil, float64(count))
+}
+
+type entry struct {
+	name   string
+	labels []string // key, value pairs
+	metric metric
+}
+
+// Registry is set of named metrics with labels
+type Registry struct {
+	sync.Mutex
+	entries map[string]*entry
+}
+
+// NewRegistry creates empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]*entry),
+	}
+}
+
+// Default is registry for all metrics of graphite-clickhouse
+var Default = NewRegistry()
+
+func (r *Registry) get(name string, labels []string, create func() metric) metric {
+	key := name + "{" + strings.Join(labels, ",") + "}"
+
+	r.Lock()
+	defer r.Unlock()
+
+	if e, ok := r.entries[key]; ok {
+		return e.metric
+	}
+
+	e := &entry{name: name, labels: labels, metric: create()}
+	r.entries[key] = e
+	return e.metric
+}
+
+// Counter returns counter with name and labels (key, value pairs). Counter is created on first call
+func (r *Registry) Counter(name string, labels ...string) *Counter {
+	return r.get(name, labels, func() metric { return &Counter{} }).(*Counter)
+}
+
+// Histogram returns histogram of durations in seconds with name and labels (key, value pairs)
+func (r *Registry) Histogram(name string, labels ...string) *Histogram {
+	return r.get(name, labels, func() metric { return newHistogram(DefaultBuckets) }).(*Histogram)
+}
+
+// sorted returns entries sorted by name and labels
+func (r *Registry) sorted() []*entry {
+	r.Lock()
+	keys := make([]string, 0, len(r.entries))
+	for k := range r.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]*entry, len(keys))
+	for i, k := range keys {
+		entries[i] = r.entries[k]
+	}
+	r.Unlock()
+
+	return entries
+}
+
+// NewCounter returns counter from Default registry
+func NewCounter(name string, labels ...string) *Counter {
+	return Default.Counter(name, labels...)
+}
+
+// NewHistogram returns histogram from Default registry
+func NewHistogram(name string, labels ...string) *Histogram {
+	return Default.Histogram(name, labels...)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
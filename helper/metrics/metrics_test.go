@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRegistry()
+	r.Counter("requests_total", "handler", "render").Add(3)
+	r.Counter("requests_total", "handler", "find").Inc()
+
+	h := r.Histogram("request_duration_seconds", "handler", "render")
+	h.Observe(0.003)
+	h.Observe(0.2)
+	h.Observe(100)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(r.WritePrometheus(buf))
+	out := buf.String()
+
+	assert.Equal(1, strings.Count(out, "# TYPE graphite_clickhouse_requests_total counter\n"))
+	assert.Contains(out, "graphite_clickhouse_requests_total{handler=\"render\"} 3\n")
+	assert.Contains(out, "graphite_clickhouse_requests_total{handler=\"find\"} 1\n")
+	assert.Contains(out, "# TYPE graphite_clickhouse_request_duration_seconds histogram\n")
+	assert.Contains(out, "graphite_clickhouse_request_duration_seconds_bucket{handler=\"render\",le=\"0.005\"} 1\n")
+	assert.Contains(out, "graphite_clickhouse_request_duration_seconds_bucket{handler=\"render\",le=\"0.25\"} 2\n")
+	assert.Contains(out, "graphite_clickhouse_request_duration_seconds_bucket{handler=\"render\",le=\"+Inf\"} 3\n")
+	assert.Contains(out, "graphite_clickhouse_request_duration_seconds_count{handler=\"render\"} 3\n")
+}
+
+func TestWriteGraphite(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRegistry()
+	r.Counter("query_bytes_total", "table", "default.graphite").Add(100)
+	r.Histogram("query_duration_seconds", "table", "graphite_tree").Observe(0.5)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(r.WriteGraphite(buf, "carbon.gch", time.Unix(1000, 0)))
+
+	assert.Equal(
+		"carbon.gch.query_bytes_total.default_graphite 100 1000\n"+
+			"carbon.gch.query_duration_seconds.graphite_tree.sum 0.5 1000\n"+
+			"carbon.gch.query_duration_seconds.graphite_tree.count 1 1000\n",
+		buf.String(),
+	)
+}
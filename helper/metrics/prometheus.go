@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Namespace is prefix of metric names in prometheus format
+var Namespace = "graphite_clickhouse"
+
+func promLabels(labels []string, extra []string) string {
+	all := append(append([]string{}, labels...), extra...)
+	if len(all) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(all)/2)
+	for i := 0; i+1 < len(all); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(all[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, all[i], v))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// WritePrometheus writes all metrics of registry in prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	var lastName string
+	for _, e := range r.sorted() {
+		name := Namespace + "_" + e.name
+		if name != lastName {
+			fmt.Fprintf(b, "# TYPE %s %s\n", name, e.metric.kind())
+			lastName = name
+		}
+
+		e.metric.each(func(suffix string, extraLabels []string, value float64) {
+			fmt.Fprintf(b, "%s%s%s %s\n", name, suffix, promLabels(e.labels, extraLabels), formatFloat(value))
+		})
+	}
+
+	return b.Flush()
+}
+
+// Handler serves metrics of Default registry in prometheus text format
+type Handler struct{}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	Default.WritePrometheus(w)
+}
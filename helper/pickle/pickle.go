@@ -96,3 +96,14 @@ func (p *Writer) Bool(b bool) {
 		p.w.Write([]byte{'I', '0', '0', '\n'})
 	}
 }
+
+func (p *Writer) Float64(v float64) {
+	u := math.Float64bits(v)
+
+	var b [9]byte
+	b[0] = 'G'
+
+	binary.BigEndian.PutUint64(b[1:9], uint64(u))
+
+	p.w.Write(b[:])
+}
@@ -16,6 +16,18 @@ func NewPoints() *Points {
 	}
 }
 
+// Copy returns Points with own copy of list. Metric names are shared with original
+func (pp *Points) Copy() *Points {
+	list := make([]Point, len(pp.list))
+	copy(list, pp.list)
+
+	return &Points{
+		list:    list,
+		idMap:   pp.idMap,
+		metrics: pp.metrics,
+	}
+}
+
 func (pp *Points) AppendPoint(metricID uint32, value float64, time uint32, version uint32) {
 	pp.list = append(pp.list, Point{
 		MetricID:  metricID,
@@ -25,6 +37,19 @@ func (pp *Points) AppendPoint(metricID uint32, value float64, time uint32, versi
 	})
 }
 
+// Merge appends points of other with metric ids of pp
+func (pp *Points) Merge(other *Points) {
+	ids := make([]uint32, len(other.metrics)+1)
+	for i, name := range other.metrics {
+		ids[i+1] = pp.MetricID(name)
+	}
+
+	for _, p := range other.list {
+		p.MetricID = ids[p.MetricID]
+		pp.list = append(pp.list, p)
+	}
+}
+
 func (pp *Points) MetricID(metricName string) uint32 {
 	id := pp.idMap[metricName]
 	if id == 0 {
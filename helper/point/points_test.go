@@ -0,0 +1,27 @@
+package point
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	pp := NewPoints()
+	pp.AppendPoint(pp.MetricID("a"), 1, 10, 10)
+
+	other := NewPoints()
+	other.AppendPoint(other.MetricID("b"), 2, 10, 10)
+	other.AppendPoint(other.MetricID("a"), 3, 20, 20)
+
+	pp.Merge(other)
+
+	assert.Equal([]Point{
+		{MetricID: 1, Value: 1, Time: 10, Timestamp: 10},
+		{MetricID: 2, Value: 2, Time: 10, Timestamp: 10},
+		{MetricID: 1, Value: 3, Time: 20, Timestamp: 20},
+	}, pp.List())
+	assert.Equal("b", pp.MetricName(2))
+}
@@ -161,6 +161,21 @@ func (r *Rollup) Step(metric string, from uint32) uint32 {
 	return pattern.Retention[len(pattern.Retention)-1].Precision
 }
 
+// Precisions returns precisions of retentions applied one by one to points since fromTimestamp
+func (rr *Pattern) Precisions(fromTimestamp uint32) []uint32 {
+	now := uint32(time.Now().Unix())
+	precisions := make([]uint32, 0, len(rr.Retention))
+
+	for _, retention := range rr.Retention {
+		if fromTimestamp+retention.Age > now && retention.Age != 0 {
+			break
+		}
+		precisions = append(precisions, retention.Precision)
+	}
+
+	return precisions
+}
+
 func doMetricPrecision(points []point.Point, precision uint32, aggr func([]point.Point) float64) []point.Point {
 	l := len(points)
 	var i, n int
@@ -207,19 +222,43 @@ func (r *Rollup) RollupMetric(metricName string, fromTimestamp uint32, points []
 		return points, 1
 	}
 
-	now := uint32(time.Now().Unix())
 	rule := r.Match(metricName)
 	precision := uint32(1)
 
-	for _, retention := range rule.Retention {
-		if fromTimestamp+retention.Age > now && retention.Age != 0 {
-			break
-		}
-
-		points = doMetricPrecision(points, retention.Precision, rule.aggr)
-		precision = retention.Precision
+	for _, p := range rule.Precisions(fromTimestamp) {
+		points = doMetricPrecision(points, p, rule.aggr)
+		precision = p
 	}
 
 	// pp.Println(points)
 	return points, precision
 }
+
+// MaxDataPointsStep returns step multiple of precision with count of points in [from, until] not more than maxDataPoints.
+// Same as consolidation in graphite-web: step * ceil(pointsCount / maxDataPoints)
+func MaxDataPointsStep(precision uint32, from uint32, until uint32, maxDataPoints int) uint32 {
+	if maxDataPoints <= 0 || precision == 0 || until <= from {
+		return precision
+	}
+
+	count := (until-from)/precision + 1
+	if count <= uint32(maxDataPoints) {
+		return precision
+	}
+
+	valuesPerPoint := (count + uint32(maxDataPoints) - 1) / uint32(maxDataPoints)
+	return precision * valuesPerPoint
+}
+
+// RollupMetricMaxDataPoints works like RollupMetric and then consolidates points with rollup function
+// to MaxDataPointsStep if maxDataPoints > 0
+func (r *Rollup) RollupMetricMaxDataPoints(metricName string, from uint32, until uint32, maxDataPoints int, points []point.Point) ([]point.Point, uint32) {
+	points, precision := r.RollupMetric(metricName, from, points)
+
+	step := MaxDataPointsStep(precision, from, until, maxDataPoints)
+	if step == precision || len(points) == 0 {
+		return points, precision
+	}
+
+	return doMetricPrecision(points, step, r.Match(metricName).aggr), step
+}
@@ -185,3 +185,105 @@ func TestMetricStep(t *testing.T) {
 		})
 	}
 }
+
+func TestPatternPrecisions(t *testing.T) {
+	r, err := ParseXML([]byte(`
+<graphite_rollup>
+ 	<default>
+ 		<function>max</function>
+ 		<retention>
+ 			<age>0</age>
+ 			<precision>60</precision>
+ 		</retention>
+ 		<retention>
+ 			<age>3600</age>
+ 			<precision>300</precision>
+ 		</retention>
+ 		<retention>
+ 			<age>86400</age>
+ 			<precision>3600</precision>
+ 		</retention>
+ 	</default>
+</graphite_rollup>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := uint32(time.Now().Unix())
+
+	tests := []struct {
+		from     uint32
+		expected []uint32
+	}{
+		{now - 500, []uint32{60}},
+		{now - 3700, []uint32{60, 300}},
+		{now - 87000, []uint32{60, 300, 3600}},
+	}
+
+	for _, test := range tests {
+		p := r.Default.Precisions(test.from)
+		if fmt.Sprint(p) != fmt.Sprint(test.expected) {
+			t.Fatalf("from=now-%v, expected %v, actual %v", now-test.from, test.expected, p)
+		}
+	}
+}
+
+func TestMaxDataPointsStep(t *testing.T) {
+	tests := []struct {
+		precision     uint32
+		from          uint32
+		until         uint32
+		maxDataPoints int
+		expectedStep  uint32
+	}{
+		{60, 0, 3600, 0, 60},
+		{60, 0, 3600, 1000, 60},
+		{60, 0, 3600, 61, 60},
+		{60, 0, 3600, 60, 120},
+		{60, 0, 3600, 10, 420},
+		{60, 0, 86400, 100, 900},
+		{60, 3600, 0, 100, 60},
+	}
+
+	for _, test := range tests {
+		step := MaxDataPointsStep(test.precision, test.from, test.until, test.maxDataPoints)
+		if step != test.expectedStep {
+			t.Fatalf("%#v: actual step=%v", test, step)
+		}
+	}
+}
+
+func TestRollupMetricMaxDataPoints(t *testing.T) {
+	r, err := ParseXML([]byte(`
+<graphite_rollup>
+ 	<default>
+ 		<function>sum</function>
+ 		<retention>
+ 			<age>0</age>
+ 			<precision>10</precision>
+ 		</retention>
+ 	</default>
+</graphite_rollup>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := uint32(time.Now().Unix()) - 100
+	from = from - from%60
+
+	in := []point.Point{
+		{MetricID: 1, Time: from, Value: 1},
+		{MetricID: 1, Time: from + 10, Value: 2},
+		{MetricID: 1, Time: from + 25, Value: 3},
+		{MetricID: 1, Time: from + 60, Value: 4},
+	}
+
+	result, step := r.RollupMetricMaxDataPoints("foo.bar", from, from+59, 2, in)
+	if step != 30 {
+		t.Fatalf("actual step=%v", step)
+	}
+
+	point.AssertListEq(t, []point.Point{
+		{MetricID: 1, Time: from, Value: 6},
+		{MetricID: 1, Time: from + 60, Value: 4},
+	}, result)
+}
@@ -0,0 +1,161 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+)
+
+type Handler struct {
+	config *config.Config
+}
+
+func NewHandler(config *config.Config) *Handler {
+	return &Handler{
+		config: config,
+	}
+}
+
+// parseRange returns from and until parameters.
+// data-table is selected by time range. Without range use table with fresh points
+func parseRange(r *http.Request) (int64, int64, error) {
+	var err error
+
+	until := time.Now().Unix()
+	from := until
+
+	if r.FormValue("from") != "" {
+		from, err = strconv.ParseInt(r.FormValue("from"), 10, 32)
+		if err != nil {
+			return 0, 0, errors.New("Bad request (malformed from)")
+		}
+	}
+
+	if r.FormValue("until") != "" {
+		until, err = strconv.ParseInt(r.FormValue("until"), 10, 32)
+		if err != nil {
+			return 0, 0, errors.New("Bad request (malformed until)")
+		}
+	}
+
+	return from, until, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(1024 * 1024)
+
+	if r.FormValue("format") == "carbonapi_v3_pb" {
+		h.serveCarbonapiV3(w, r)
+		return
+	}
+
+	target := r.FormValue("target")
+	if target == "" {
+		http.Error(w, "Bad request (no target)", http.StatusBadRequest)
+		return
+	}
+
+	from, until, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	i, err := New(h.config, r.Context(), target, from, until)
+	if err != nil {
+		http.Error(w, err.Error(), finder.HTTPStatus(err))
+		return
+	}
+
+	if !i.Found() {
+		http.Error(w, fmt.Sprintf("Metric %#v not found", target), http.StatusNotFound)
+		return
+	}
+
+	h.Reply(w, r, i)
+}
+
+func (h *Handler) Reply(w http.ResponseWriter, r *http.Request, i *Info) {
+	var err error
+
+	switch r.FormValue("format") {
+	case "pickle":
+		err = i.WritePickle(w)
+	case "protobuf", "carbonapi_v2_pb":
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		err = i.WriteProtobuf(w)
+	case "json", "":
+		w.Header().Set("Content-Type", "application/json")
+		err = i.WriteJSON(w)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown format %#v", r.FormValue("format")), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serveCarbonapiV3 replies to carbonapi v3 MultiMetricsInfoRequest. Not found metrics are skipped.
+// Request without body is made from target parameters
+func (h *Handler) serveCarbonapiV3(w http.ResponseWriter, r *http.Request) {
+	from, until, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &carbonapi_v3_pb.MultiMetricsInfoRequest{}
+	if len(body) > 0 {
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, fmt.Sprintf("Bad request (malformed MultiMetricsInfoRequest: %s)", err.Error()), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Names = r.Form["target"]
+	}
+
+	response := &carbonapi_v3_pb.MultiMetricsInfoResponse{}
+
+	for _, target := range req.Names {
+		i, err := New(h.config, r.Context(), target, from, until)
+		if err != nil {
+			http.Error(w, err.Error(), finder.HTTPStatus(err))
+			return
+		}
+
+		if i.Found() {
+			response.Metrics = append(response.Metrics, i.MetricsInfoV3())
+		}
+	}
+
+	if len(response.Metrics) == 0 {
+		http.Error(w, "Metrics not found", http.StatusNotFound)
+		return
+	}
+
+	b, err := proto.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(b)
+}
@@ -0,0 +1,169 @@
+package info
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
+	"github.com/lomik/graphite-clickhouse/carbonzipperpb"
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+const testRollup = `
+<graphite_rollup>
+ 	<pattern>
+ 		<regexp>^click_cost\.</regexp>
+ 		<function>any</function>
+ 		<retention>
+ 			<age>0</age>
+ 			<precision>60</precision>
+ 		</retention>
+ 		<retention>
+ 			<age>86400</age>
+ 			<precision>3600</precision>
+ 		</retention>
+ 	</pattern>
+ 	<default>
+ 		<function>max</function>
+ 		<retention>
+ 			<age>0</age>
+ 			<precision>60</precision>
+ 		</retention>
+ 	</default>
+</graphite_rollup>
+`
+
+type clickhouseMock struct {
+	body string
+}
+
+func (m *clickhouseMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(m.body))
+}
+
+func TestInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	testCase := func(target string, chResponse string, expectedStatus int, expected *carbonzipperpb.InfoResponse) {
+		srv := httptest.NewServer(&clickhouseMock{body: chResponse})
+		defer srv.Close()
+
+		cfg := config.New()
+		cfg.ClickHouse.Url = srv.URL
+		cfg.ClickHouse.DataRetention = &config.Duration{Duration: 30 * 24 * time.Hour}
+
+		var err error
+		cfg.Rollup, err = rollup.ParseXML([]byte(testRollup))
+		assert.NoError(err)
+
+		handler := NewHandler(cfg)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			"GET",
+			"http://localhost/info/?format=json&target="+target,
+			nil,
+		)
+
+		r = r.WithContext(context.WithValue(r.Context(), "logger", zap.NewNop()))
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(expectedStatus, w.Code, target)
+		if expected == nil {
+			return
+		}
+
+		var actual carbonzipperpb.InfoResponse
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &actual), target)
+		assert.Equal(*expected, actual, target)
+	}
+
+	testCase("click_cost.sum", "click_cost.sum\n", http.StatusOK, &carbonzipperpb.InfoResponse{
+		Name:              "click_cost.sum",
+		AggregationMethod: "any",
+		MaxRetention:      2592000,
+		Retentions: []*carbonzipperpb.Retention{
+			{SecondsPerPoint: 60, NumberOfPoints: 1440},
+			{SecondsPerPoint: 3600, NumberOfPoints: 696},
+		},
+	})
+
+	testCase("host.cpu", "host.cpu\n", http.StatusOK, &carbonzipperpb.InfoResponse{
+		Name:              "host.cpu",
+		AggregationMethod: "max",
+		MaxRetention:      2592000,
+		Retentions: []*carbonzipperpb.Retention{
+			{SecondsPerPoint: 60, NumberOfPoints: 43200},
+		},
+	})
+
+	testCase("host.unknown", "", http.StatusNotFound, nil)
+}
+
+func TestInfoCarbonapiV3(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("click_cost")) {
+			w.Write([]byte("click_cost.sum\n"))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+
+	var err error
+	cfg.Rollup, err = rollup.ParseXML([]byte(testRollup))
+	assert.NoError(err)
+
+	body, err := proto.Marshal(&carbonapi_v3_pb.MultiMetricsInfoRequest{
+		Names: []string{"click_cost.sum", "host.unknown"},
+	})
+	assert.NoError(err)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "http://localhost/info/?format=carbonapi_v3_pb", bytes.NewReader(body))
+	NewHandler(cfg).ServeHTTP(w, r)
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	var response carbonapi_v3_pb.MultiMetricsInfoResponse
+	assert.NoError(proto.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal([]*carbonapi_v3_pb.MetricsInfoResponse{{
+		Name:              "click_cost.sum",
+		ConsolidationFunc: "any",
+		// data-retention is not configured
+		MaxRetention: 0,
+		Retentions: []*carbonapi_v3_pb.Retention{
+			{SecondsPerPoint: 60, NumberOfPoints: 1440},
+			{SecondsPerPoint: 3600, NumberOfPoints: 0},
+		},
+	}}, response.Metrics)
+}
+
+func TestInfoClickHouseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 241, e.displayText() = DB::Exception: Memory limit exceeded", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+
+	w := httptest.NewRecorder()
+	NewHandler(cfg).ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/info/?target=host.cpu", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,165 @@
+package info
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
+	"github.com/lomik/graphite-clickhouse/carbonzipperpb"
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/pickle"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+	"github.com/lomik/graphite-clickhouse/render"
+)
+
+type Info struct {
+	target   string // original target
+	response *carbonzipperpb.InfoResponse
+}
+
+// makeResponse converts rollup pattern to carbonzipper InfoResponse. dataRetention is how long points are
+// kept in data table (seconds), it is maxRetention and upper age bound of the last retention.
+// If dataRetention is unknown (0) numberOfPoints of the last retention and maxRetention are 0
+func makeResponse(name string, pattern *rollup.Pattern, dataRetention uint32) *carbonzipperpb.InfoResponse {
+	response := &carbonzipperpb.InfoResponse{
+		Name:              name,
+		AggregationMethod: pattern.Function,
+		Retentions:        make([]*carbonzipperpb.Retention, 0, len(pattern.Retention)),
+	}
+
+	for i, r := range pattern.Retention {
+		end := dataRetention
+		if i < len(pattern.Retention)-1 {
+			end = pattern.Retention[i+1].Age
+		}
+
+		var points int32
+		if r.Precision > 0 && end > r.Age {
+			points = int32((end - r.Age) / r.Precision)
+		}
+
+		response.Retentions = append(response.Retentions, &carbonzipperpb.Retention{
+			SecondsPerPoint: int32(r.Precision),
+			NumberOfPoints:  points,
+		})
+	}
+
+	response.MaxRetention = int32(dataRetention)
+
+	return response
+}
+
+func New(config *config.Config, ctx context.Context, target string, from int64, until int64) (*Info, error) {
+	res, err := finder.Find(config, ctx, target, from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Info{
+		target: target,
+	}
+
+	series := res.Series()
+	if len(series) == 0 {
+		return i, nil
+	}
+
+	_, _, rollupObj, _ := render.SelectDataTable(config, from, until, []string{target})
+
+	i.response = makeResponse(
+		string(res.Abs(series[0])),
+		rollupObj.Match(string(series[0])),
+		uint32(config.ClickHouse.DataRetention.Value().Seconds()),
+	)
+
+	return i, nil
+}
+
+// Found returns false if target doesn't match any metric
+func (i *Info) Found() bool {
+	return i.response != nil
+}
+
+// MetricsInfoV3 returns info as carbonapi v3 MetricsInfoResponse
+func (i *Info) MetricsInfoV3() *carbonapi_v3_pb.MetricsInfoResponse {
+	response := &carbonapi_v3_pb.MetricsInfoResponse{
+		Name:              i.response.Name,
+		ConsolidationFunc: i.response.AggregationMethod,
+		XFilesFactor:      i.response.XFilesFactor,
+		MaxRetention:      int64(i.response.MaxRetention),
+		Retentions:        make([]*carbonapi_v3_pb.Retention, 0, len(i.response.Retentions)),
+	}
+
+	for _, r := range i.response.Retentions {
+		response.Retentions = append(response.Retentions, &carbonapi_v3_pb.Retention{
+			SecondsPerPoint: int64(r.SecondsPerPoint),
+			NumberOfPoints:  int64(r.NumberOfPoints),
+		})
+	}
+
+	return response
+}
+
+func (i *Info) WriteJSON(w io.Writer) error {
+	body, err := json.Marshal(i.response)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	return err
+}
+
+func (i *Info) WriteProtobuf(w io.Writer) error {
+	body, err := i.response.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	return err
+}
+
+func (i *Info) WritePickle(w io.Writer) error {
+	p := pickle.NewWriter(w)
+
+	p.Dict()
+
+	p.String("name")
+	p.String(i.response.Name)
+	p.SetItem()
+
+	p.String("aggregationMethod")
+	p.String(i.response.AggregationMethod)
+	p.SetItem()
+
+	p.String("maxRetention")
+	p.Uint32(uint32(i.response.MaxRetention))
+	p.SetItem()
+
+	p.String("xFilesFactor")
+	p.Float64(float64(i.response.XFilesFactor))
+	p.SetItem()
+
+	p.String("retentions")
+	p.List()
+	for _, r := range i.response.Retentions {
+		p.Dict()
+
+		p.String("secondsPerPoint")
+		p.Uint32(uint32(r.SecondsPerPoint))
+		p.SetItem()
+
+		p.String("numberOfPoints")
+		p.Uint32(uint32(r.NumberOfPoints))
+		p.SetItem()
+
+		p.Append()
+	}
+	p.SetItem()
+
+	p.Stop()
+	return nil
+}
@@ -0,0 +1,422 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/prompb"
+)
+
+// Max number of points per series in query_range response. Same as in prometheus
+const maxPointsPerSeries = 11000
+
+// APIHandler serves prometheus HTTP API (/api/v1/...)
+type APIHandler struct {
+	config *config.Config
+	reader *Handler
+}
+
+func NewAPIHandler(config *config.Config) *APIHandler {
+	return &APIHandler{
+		config: config,
+		reader: NewHandler(config),
+	}
+}
+
+type apiResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type apiQueryData struct {
+	ResultType string      `json:"resultType"`
+	Result     interface{} `json:"result"`
+}
+
+type matrixSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]interface{}  `json:"values"`
+}
+
+type vectorSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"`
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTimestamp(ms int64) float64 {
+	return float64(ms) / 1000
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, response *apiResponse) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeAPIData(w http.ResponseWriter, data interface{}) {
+	writeAPIResponse(w, http.StatusOK, &apiResponse{Status: "success", Data: data})
+}
+
+func writeAPIError(w http.ResponseWriter, status int, errorType string, err error) {
+	writeAPIResponse(w, status, &apiResponse{Status: "error", ErrorType: errorType, Error: err.Error()})
+}
+
+// parseTime parses unix timestamp with optional fraction or RFC3339 time
+func parseTime(s string, defaultValue time.Time) (time.Time, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse %#v to a valid timestamp", s)
+}
+
+// parseStep parses float number of seconds or prometheus duration
+func parseStep(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+
+	return ParseDuration(s)
+}
+
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(1024 * 1024)
+
+	path := r.URL.Path
+
+	switch {
+	case path == "/api/v1/query":
+		h.serveQuery(w, r)
+	case path == "/api/v1/query_range":
+		h.serveQueryRange(w, r)
+	case path == "/api/v1/series":
+		h.serveSeries(w, r)
+	case path == "/api/v1/labels":
+		h.serveLabels(w, r)
+	case strings.HasPrefix(path, "/api/v1/label/") && strings.HasSuffix(path, "/values"):
+		h.serveLabelValues(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/label/"), "/values"))
+	default:
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Errorf("unknown API endpoint %#v", path))
+	}
+}
+
+// fetch returns rolled up samples of all series matched by matchers. Timestamps in milliseconds
+func (h *APIHandler) fetch(ctx context.Context, matchers []*prompb.LabelMatcher, from, until int64) ([]*prompb.TimeSeries, error) {
+	q := &prompb.Query{
+		StartTimestampMs: from,
+		EndTimestampMs:   until,
+		Matchers:         matchers,
+	}
+
+	series, err := h.reader.series(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := h.reader.queryData(ctx, q, series)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Timeseries, nil
+}
+
+func (h *APIHandler) evaluate(w http.ResponseWriter, r *http.Request, start, end time.Time, step time.Duration) (*evaluator, *evalResult, bool) {
+	expr, err := ParseExpr(r.FormValue("query"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", err)
+		return nil, nil, false
+	}
+
+	ev := newEvaluator(r.Context(), h.fetch, start, end, step)
+	res, err := ev.eval(expr)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "execution", err)
+		return nil, nil, false
+	}
+
+	return ev, res, true
+}
+
+func (h *APIHandler) serveQuery(w http.ResponseWriter, r *http.Request) {
+	ts, err := parseTime(r.FormValue("time"), time.Now())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	ev, res, ok := h.evaluate(w, r, ts, ts, time.Second)
+	if !ok {
+		return
+	}
+
+	t := formatTimestamp(ev.timestamp(0))
+
+	if res.isScalar {
+		writeAPIData(w, &apiQueryData{
+			ResultType: "scalar",
+			Result:     [2]interface{}{t, formatValue(res.scalar[0])},
+		})
+		return
+	}
+
+	result := make([]vectorSample, 0, len(res.series))
+	for _, s := range res.series {
+		if math.IsNaN(s.values[0]) {
+			continue
+		}
+		result = append(result, vectorSample{
+			Metric: s.labels,
+			Value:  [2]interface{}{t, formatValue(s.values[0])},
+		})
+	}
+
+	writeAPIData(w, &apiQueryData{ResultType: "vector", Result: result})
+}
+
+func (h *APIHandler) serveQueryRange(w http.ResponseWriter, r *http.Request) {
+	start, err := parseTime(r.FormValue("start"), time.Time{})
+	if err != nil || start.IsZero() {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter 'start'"))
+		return
+	}
+
+	end, err := parseTime(r.FormValue("end"), time.Time{})
+	if err != nil || end.IsZero() {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter 'end'"))
+		return
+	}
+
+	if end.Before(start) {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("end timestamp must not be before start time"))
+		return
+	}
+
+	step, err := parseStep(r.FormValue("step"))
+	if err != nil || step <= 0 {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter 'step'"))
+		return
+	}
+
+	if end.Sub(start)/step > maxPointsPerSeries {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX)", maxPointsPerSeries))
+		return
+	}
+
+	ev, res, ok := h.evaluate(w, r, start, end, step)
+	if !ok {
+		return
+	}
+
+	if res.isScalar {
+		res.series = []*evalSeries{{labels: map[string]string{}, values: res.scalar}}
+	}
+
+	result := make([]matrixSeries, 0, len(res.series))
+	for _, s := range res.series {
+		m := matrixSeries{
+			Metric: s.labels,
+			Values: make([][2]interface{}, 0, len(s.values)),
+		}
+		for i, v := range s.values {
+			if math.IsNaN(v) {
+				continue
+			}
+			m.Values = append(m.Values, [2]interface{}{formatTimestamp(ev.timestamp(i)), formatValue(v)})
+		}
+		if len(m.Values) > 0 {
+			result = append(result, m)
+		}
+	}
+
+	writeAPIData(w, &apiQueryData{ResultType: "matrix", Result: result})
+}
+
+// timeRange returns start and end params or last tagged-autocomplete-days days
+func (h *APIHandler) timeRange(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+
+	start, err := parseTime(r.FormValue("start"), now.AddDate(0, 0, -h.config.ClickHouse.TaggedAutocompleDays))
+	if err != nil {
+		return start, now, err
+	}
+
+	end, err := parseTime(r.FormValue("end"), now)
+	return start, end, err
+}
+
+// pathLabels converts tagged path "name?k=v" to labels
+func pathLabels(path string) (map[string]string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{"__name__": u.Path}
+	for k, v := range u.Query() {
+		labels[k] = v[0]
+	}
+
+	return labels, nil
+}
+
+func (h *APIHandler) serveSeries(w http.ResponseWriter, r *http.Request) {
+	match := r.Form["match[]"]
+	if len(match) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("no match[] parameter provided"))
+		return
+	}
+
+	start, end, err := h.timeRange(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	result := make([]map[string]string, 0)
+
+	for _, m := range match {
+		expr, err := ParseExpr(m)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "bad_data", err)
+			return
+		}
+
+		sel, ok := expr.(*VectorSelector)
+		if !ok || sel.Range != 0 {
+			writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("match[] %#v is not an instant vector selector", m))
+			return
+		}
+
+		series, err := h.reader.series(r.Context(), &prompb.Query{
+			StartTimestampMs: start.Unix() * 1000,
+			EndTimestampMs:   end.Unix() * 1000,
+			Matchers:         sel.Matchers,
+		})
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "execution", err)
+			return
+		}
+
+		for _, s := range series {
+			if len(s) == 0 || seen[string(s)] {
+				continue
+			}
+			seen[string(s)] = true
+
+			labels, err := pathLabels(string(s))
+			if err != nil {
+				continue
+			}
+			result = append(result, labels)
+		}
+	}
+
+	writeAPIData(w, result)
+}
+
+func (h *APIHandler) queryTagValues(r *http.Request, valueSQL string, where *finder.Where) ([]string, error) {
+	start, end, err := h.timeRange(r)
+	if err != nil {
+		return nil, err
+	}
+
+	where.Andf(
+		"Date >='%s' AND Date <= '%s'",
+		start.Format("2006-01-02"),
+		end.Format("2006-01-02"),
+	)
+	where.And("Deleted = 0")
+
+	sql := fmt.Sprintf("SELECT %s FROM %s %s GROUP BY value ORDER BY value",
+		valueSQL,
+		h.config.ClickHouse.TaggedTable,
+		where.SQL(),
+	)
+
+	body, err := clickhouse.Query(r.Context(), h.config.ClickHouse.Url, sql, h.config.ClickHouse.TaggedTable,
+		clickhouse.Options{Timeout: h.config.ClickHouse.TreeTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := strings.Split(string(body), "\n")
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row != "" {
+			values = append(values, row)
+		}
+	}
+	sort.Strings(values)
+
+	return values, nil
+}
+
+func (h *APIHandler) serveLabels(w http.ResponseWriter, r *http.Request) {
+	values, err := h.queryTagValues(r, finder.TagNameSQL("Tag1")+" AS value", finder.NewWhere())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "execution", err)
+		return
+	}
+
+	writeAPIData(w, values)
+}
+
+func (h *APIHandler) serveLabelValues(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" || strings.IndexByte(name, '/') >= 0 {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid label name %#v", name))
+		return
+	}
+
+	where := finder.NewWhere()
+	where.And(finder.TagValueWhere("Tag1", name, ""))
+
+	values, err := h.queryTagValues(r, finder.TagValueSQL("Tag1", name)+" AS value", where)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "execution", err)
+		return
+	}
+
+	writeAPIData(w, values)
+}
@@ -0,0 +1,172 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/RowBinary"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func newAPIServer(t *testing.T, response func(query []byte) []byte) (*clickhouse.TestServer, *APIHandler) {
+	srv := clickhouse.NewTestServer()
+	srv.SetResponse(response)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(t, err)
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+	cfg.Rollup = r
+
+	return srv, NewAPIHandler(cfg)
+}
+
+func apiGet(h http.Handler, url string) (int, *apiResponse) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+
+	response := &apiResponse{}
+	json.Unmarshal(w.Body.Bytes(), response)
+	return w.Code, response
+}
+
+func TestAPISeries(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, h := newAPIServer(t, func(query []byte) []byte {
+		return []byte("up?job=node\nup?job=web\n")
+	})
+	defer srv.Close()
+
+	code, response := apiGet(h, `/api/v1/series?match[]=up&start=1520056800&end=1520060400`)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]interface{}{
+		map[string]interface{}{"__name__": "up", "job": "node"},
+		map[string]interface{}{"__name__": "up", "job": "web"},
+	}, response.Data)
+
+	requests := srv.Requests()
+	if assert.Len(requests, 1) {
+		assert.Contains(string(requests[0].Query), "(Tag1='__name__=up')")
+		assert.Contains(string(requests[0].Query), "Date >='2018-03-03' AND Date <= '2018-03-03'")
+	}
+
+	code, _ = apiGet(h, `/api/v1/series`)
+	assert.Equal(http.StatusBadRequest, code)
+
+	code, _ = apiGet(h, `/api/v1/series?match[]=rate(up[5m])`)
+	assert.Equal(http.StatusBadRequest, code)
+}
+
+func TestAPILabels(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, h := newAPIServer(t, func(query []byte) []byte {
+		return []byte("job\n__name__\n")
+	})
+	defer srv.Close()
+
+	code, response := apiGet(h, `/api/v1/labels`)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]interface{}{"__name__", "job"}, response.Data)
+
+	requests := srv.Requests()
+	if assert.Len(requests, 1) {
+		assert.Contains(string(requests[0].Query), "splitByChar('=', Tag1)[1] AS value")
+	}
+}
+
+func TestAPILabelValues(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, h := newAPIServer(t, func(query []byte) []byte {
+		return []byte("node\na=b\n")
+	})
+	defer srv.Close()
+
+	code, response := apiGet(h, `/api/v1/label/my_job/values`)
+	assert.Equal(http.StatusOK, code)
+	// value with '=' is not truncated
+	assert.Equal([]interface{}{"a=b", "node"}, response.Data)
+
+	requests := srv.Requests()
+	if assert.Len(requests, 1) {
+		query := string(requests[0].Query)
+		// '_' in label name is not a LIKE wildcard
+		assert.Contains(query, "startsWith(Tag1, 'my_job=')")
+		assert.Contains(query, "substring(Tag1, 8) AS value")
+		assert.NotContains(query, "LIKE")
+	}
+
+	code, _ = apiGet(h, `/api/v1/label//values`)
+	assert.Equal(http.StatusBadRequest, code)
+}
+
+func TestAPIQueryRange(t *testing.T) {
+	assert := assert.New(t)
+
+	var from uint32 = 1520056800
+
+	srv, h := newAPIServer(t, func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tagged")) {
+			return []byte("up?job=node\n")
+		}
+
+		buf := new(bytes.Buffer)
+		w := RowBinary.NewEncoder(buf)
+		for i, v := range []float64{1, 2, 3} {
+			w.String("up?job=node")
+			w.Uint32(from + uint32(i)*60)
+			w.Float64(v)
+			w.Uint32(from + uint32(i)*60)
+		}
+		return buf.Bytes()
+	})
+	defer srv.Close()
+
+	code, response := apiGet(h, fmt.Sprintf("/api/v1/query_range?query=up&start=%d&end=%d&step=60", from, from+120))
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("success", response.Status)
+	assert.Equal(map[string]interface{}{
+		"resultType": "matrix",
+		"result": []interface{}{
+			map[string]interface{}{
+				"metric": map[string]interface{}{"__name__": "up", "job": "node"},
+				"values": []interface{}{
+					[]interface{}{float64(from), "1"},
+					[]interface{}{float64(from + 60), "2"},
+					[]interface{}{float64(from + 120), "3"},
+				},
+			},
+		},
+	}, response.Data)
+
+	code, _ = apiGet(h, fmt.Sprintf("/api/v1/query_range?query=up&start=%d&end=%d&step=60", from+120, from))
+	assert.Equal(http.StatusBadRequest, code)
+
+	code, _ = apiGet(h, fmt.Sprintf("/api/v1/query_range?query=up&start=%d&end=%d&step=0", from, from+120))
+	assert.Equal(http.StatusBadRequest, code)
+
+	code, response = apiGet(h, fmt.Sprintf("/api/v1/query_range?query=up{&start=%d&end=%d&step=60", from, from+120))
+	assert.Equal(http.StatusBadRequest, code)
+	assert.Equal("bad_data", response.ErrorType)
+}
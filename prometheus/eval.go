@@ -0,0 +1,481 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/helper/prompb"
+)
+
+// LookbackDelta is max distance between evaluation timestamp and sample for instant vector selectors
+const LookbackDelta = 5 * time.Minute
+
+// Absent values in evaluated series are stored as NaN
+
+type evalSeries struct {
+	labels map[string]string
+	values []float64 // one value per evaluation step
+}
+
+type evalResult struct {
+	isScalar bool
+	scalar   []float64
+	series   []*evalSeries
+}
+
+// fetchFunc returns raw samples for selector in [from, until] (milliseconds)
+type fetchFunc func(ctx context.Context, matchers []*prompb.LabelMatcher, from, until int64) ([]*prompb.TimeSeries, error)
+
+type evaluator struct {
+	ctx   context.Context
+	fetch fetchFunc
+	start int64 // ms
+	end   int64 // ms
+	step  int64 // ms
+	steps int
+}
+
+func newEvaluator(ctx context.Context, fetch fetchFunc, start, end time.Time, step time.Duration) *evaluator {
+	ev := &evaluator{
+		ctx:   ctx,
+		fetch: fetch,
+		start: start.UnixNano() / int64(time.Millisecond),
+		end:   end.UnixNano() / int64(time.Millisecond),
+		step:  int64(step / time.Millisecond),
+	}
+	if ev.step <= 0 {
+		ev.step = 1
+	}
+	ev.steps = int((ev.end-ev.start)/ev.step) + 1
+	return ev
+}
+
+// timestamp of i-th evaluation step in milliseconds
+func (ev *evaluator) timestamp(i int) int64 {
+	return ev.start + int64(i)*ev.step
+}
+
+func (ev *evaluator) newValues() []float64 {
+	v := make([]float64, ev.steps)
+	for i := 0; i < len(v); i++ {
+		v[i] = math.NaN()
+	}
+	return v
+}
+
+func labelsMap(labels []*prompb.Label) map[string]string {
+	m := make(map[string]string, len(labels))
+	for i := 0; i < len(labels); i++ {
+		m[labels[i].Name] = labels[i].Value
+	}
+	return m
+}
+
+// labelsKey returns canonical string representation of label set
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('\xff')
+		b.WriteString(labels[k])
+		b.WriteByte('\xff')
+	}
+	return b.String()
+}
+
+func dropName(labels map[string]string) map[string]string {
+	m := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != "__name__" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+func (ev *evaluator) eval(expr Expr) (*evalResult, error) {
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		v := make([]float64, ev.steps)
+		for i := 0; i < len(v); i++ {
+			v[i] = e.Value
+		}
+		return &evalResult{isScalar: true, scalar: v}, nil
+	case *ParenExpr:
+		return ev.eval(e.Expr)
+	case *VectorSelector:
+		if e.Range != 0 {
+			return nil, fmt.Errorf("range vector is not allowed in this context")
+		}
+		return ev.evalWindow(e, LookbackDelta, func(samples []*prompb.Sample, from, until int64) float64 {
+			return samples[len(samples)-1].Value
+		}, false)
+	case *Call:
+		if f, ok := rangeFunctions[e.Func]; ok {
+			sel := e.Args[0].(*VectorSelector)
+			return ev.evalWindow(sel, sel.Range, f, true)
+		}
+		arg, err := ev.eval(e.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		if arg.isScalar {
+			return nil, fmt.Errorf("function %#v expects instant vector argument", e.Func)
+		}
+		f := instantFunctions[e.Func]
+		for _, s := range arg.series {
+			s.labels = dropName(s.labels)
+			for i := 0; i < len(s.values); i++ {
+				if !math.IsNaN(s.values[i]) {
+					s.values[i] = f(s.values[i])
+				}
+			}
+		}
+		return arg, nil
+	case *AggregateExpr:
+		return ev.evalAggregate(e)
+	case *BinaryExpr:
+		return ev.evalBinary(e)
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+// evalWindow calls f for samples inside (ts-window, ts] for every evaluation step
+func (ev *evaluator) evalWindow(sel *VectorSelector, window time.Duration, f func(samples []*prompb.Sample, from, until int64) float64, dropMetricName bool) (*evalResult, error) {
+	offset := int64(sel.Offset / time.Millisecond)
+	windowMs := int64(window / time.Millisecond)
+
+	series, err := ev.fetch(ev.ctx, sel.Matchers, ev.start-offset-windowMs, ev.end-offset)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &evalResult{series: make([]*evalSeries, 0, len(series))}
+
+	for _, ts := range series {
+		s := &evalSeries{
+			labels: labelsMap(ts.Labels),
+			values: ev.newValues(),
+		}
+		if dropMetricName {
+			s.labels = dropName(s.labels)
+		}
+
+		samples := ts.Samples
+		var left, right int
+		for i := 0; i < ev.steps; i++ {
+			until := ev.timestamp(i) - offset
+			from := until - windowMs
+
+			for right < len(samples) && samples[right].Timestamp <= until {
+				right++
+			}
+			for left < right && samples[left].Timestamp <= from {
+				left++
+			}
+
+			if left < right {
+				s.values[i] = f(samples[left:right], from, until)
+			}
+		}
+
+		result.series = append(result.series, s)
+	}
+
+	return result, nil
+}
+
+func (ev *evaluator) evalAggregate(e *AggregateExpr) (*evalResult, error) {
+	arg, err := ev.eval(e.Expr)
+	if err != nil {
+		return nil, err
+	}
+	if arg.isScalar {
+		return nil, fmt.Errorf("aggregation %#v expects instant vector argument", e.Op)
+	}
+
+	grouping := make(map[string]bool, len(e.Grouping))
+	for _, l := range e.Grouping {
+		grouping[l] = true
+	}
+
+	type group struct {
+		series *evalSeries
+		counts []int
+	}
+
+	groups := make(map[string]*group)
+	keys := make([]string, 0)
+
+	for _, s := range arg.series {
+		labels := make(map[string]string)
+		for k, v := range s.labels {
+			if k == "__name__" {
+				continue
+			}
+			if grouping[k] != e.Without {
+				labels[k] = v
+			}
+		}
+
+		key := labelsKey(labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{
+				series: &evalSeries{labels: labels, values: ev.newValues()},
+				counts: make([]int, ev.steps),
+			}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+
+		for i, v := range s.values {
+			if math.IsNaN(v) {
+				continue
+			}
+			acc := g.series.values[i]
+			g.counts[i]++
+			switch {
+			case e.Op == "count":
+				acc = float64(g.counts[i])
+			case g.counts[i] == 1:
+				acc = v
+			case e.Op == "sum" || e.Op == "avg":
+				acc += v
+			case e.Op == "min":
+				acc = math.Min(acc, v)
+			case e.Op == "max":
+				acc = math.Max(acc, v)
+			}
+			g.series.values[i] = acc
+		}
+	}
+
+	result := &evalResult{series: make([]*evalSeries, 0, len(groups))}
+	for _, key := range keys {
+		g := groups[key]
+		if e.Op == "avg" {
+			for i := range g.series.values {
+				if g.counts[i] > 0 {
+					g.series.values[i] /= float64(g.counts[i])
+				}
+			}
+		}
+		result.series = append(result.series, g.series)
+	}
+
+	return result, nil
+}
+
+func binaryOp(op byte, a, b float64) float64 {
+	switch op {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	case '/':
+		return a / b
+	}
+	return math.NaN()
+}
+
+func (ev *evaluator) evalBinary(e *BinaryExpr) (*evalResult, error) {
+	lhs, err := ev.eval(e.LHS)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := ev.eval(e.RHS)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case lhs.isScalar && rhs.isScalar:
+		for i := range lhs.scalar {
+			lhs.scalar[i] = binaryOp(e.Op, lhs.scalar[i], rhs.scalar[i])
+		}
+		return lhs, nil
+	case rhs.isScalar:
+		for _, s := range lhs.series {
+			s.labels = dropName(s.labels)
+			for i := range s.values {
+				if !math.IsNaN(s.values[i]) {
+					s.values[i] = binaryOp(e.Op, s.values[i], rhs.scalar[i])
+				}
+			}
+		}
+		return lhs, nil
+	case lhs.isScalar:
+		for _, s := range rhs.series {
+			s.labels = dropName(s.labels)
+			for i := range s.values {
+				if !math.IsNaN(s.values[i]) {
+					s.values[i] = binaryOp(e.Op, lhs.scalar[i], s.values[i])
+				}
+			}
+		}
+		return rhs, nil
+	}
+
+	// one-to-one vector matching by all labels except __name__
+	right := make(map[string]*evalSeries, len(rhs.series))
+	for _, s := range rhs.series {
+		s.labels = dropName(s.labels)
+		key := labelsKey(s.labels)
+		if _, ok := right[key]; ok {
+			return nil, fmt.Errorf("many-to-many matching not allowed: duplicate series on the right side")
+		}
+		right[key] = s
+	}
+
+	result := &evalResult{series: make([]*evalSeries, 0)}
+	for _, s := range lhs.series {
+		s.labels = dropName(s.labels)
+		r, ok := right[labelsKey(s.labels)]
+		if !ok {
+			continue
+		}
+		for i := range s.values {
+			if math.IsNaN(s.values[i]) || math.IsNaN(r.values[i]) {
+				s.values[i] = math.NaN()
+				continue
+			}
+			s.values[i] = binaryOp(e.Op, s.values[i], r.values[i])
+		}
+		result.series = append(result.series, s)
+	}
+
+	return result, nil
+}
+
+// extrapolatedRate is the same algorithm as in prometheus
+func extrapolatedRate(samples []*prompb.Sample, from, until int64, isCounter bool, isRate bool) float64 {
+	if len(samples) < 2 {
+		return math.NaN()
+	}
+
+	first := samples[0]
+	last := samples[len(samples)-1]
+
+	resultValue := last.Value - first.Value
+	if isCounter {
+		for i := 1; i < len(samples); i++ {
+			if samples[i].Value < samples[i-1].Value {
+				resultValue += samples[i-1].Value
+			}
+		}
+	}
+
+	durationToStart := float64(first.Timestamp-from) / 1000
+	durationToEnd := float64(until-last.Timestamp) / 1000
+	sampledInterval := float64(last.Timestamp-first.Timestamp) / 1000
+	averageDurationBetweenSamples := sampledInterval / float64(len(samples)-1)
+
+	if isCounter && resultValue > 0 && first.Value >= 0 {
+		durationToZero := sampledInterval * (first.Value / resultValue)
+		if durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+
+	extrapolationThreshold := averageDurationBetweenSamples * 1.1
+	extrapolateToInterval := sampledInterval
+
+	if durationToStart < extrapolationThreshold {
+		extrapolateToInterval += durationToStart
+	} else {
+		extrapolateToInterval += averageDurationBetweenSamples / 2
+	}
+	if durationToEnd < extrapolationThreshold {
+		extrapolateToInterval += durationToEnd
+	} else {
+		extrapolateToInterval += averageDurationBetweenSamples / 2
+	}
+
+	resultValue = resultValue * (extrapolateToInterval / sampledInterval)
+	if isRate {
+		resultValue = resultValue / (float64(until-from) / 1000)
+	}
+
+	return resultValue
+}
+
+var rangeFunctions = map[string]func(samples []*prompb.Sample, from, until int64) float64{
+	"rate": func(samples []*prompb.Sample, from, until int64) float64 {
+		return extrapolatedRate(samples, from, until, true, true)
+	},
+	"increase": func(samples []*prompb.Sample, from, until int64) float64 {
+		return extrapolatedRate(samples, from, until, true, false)
+	},
+	"delta": func(samples []*prompb.Sample, from, until int64) float64 {
+		return extrapolatedRate(samples, from, until, false, false)
+	},
+	"irate": func(samples []*prompb.Sample, from, until int64) float64 {
+		if len(samples) < 2 {
+			return math.NaN()
+		}
+		last := samples[len(samples)-1]
+		prev := samples[len(samples)-2]
+		d := last.Value - prev.Value
+		if last.Value < prev.Value {
+			d = last.Value
+		}
+		return d / (float64(last.Timestamp-prev.Timestamp) / 1000)
+	},
+	"avg_over_time": func(samples []*prompb.Sample, from, until int64) float64 {
+		var sum float64
+		for _, s := range samples {
+			sum += s.Value
+		}
+		return sum / float64(len(samples))
+	},
+	"sum_over_time": func(samples []*prompb.Sample, from, until int64) float64 {
+		var sum float64
+		for _, s := range samples {
+			sum += s.Value
+		}
+		return sum
+	},
+	"min_over_time": func(samples []*prompb.Sample, from, until int64) float64 {
+		r := samples[0].Value
+		for _, s := range samples {
+			r = math.Min(r, s.Value)
+		}
+		return r
+	},
+	"max_over_time": func(samples []*prompb.Sample, from, until int64) float64 {
+		r := samples[0].Value
+		for _, s := range samples {
+			r = math.Max(r, s.Value)
+		}
+		return r
+	},
+	"count_over_time": func(samples []*prompb.Sample, from, until int64) float64 {
+		return float64(len(samples))
+	},
+}
+
+var instantFunctions = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+	"round": math.Round,
+	"sqrt":  math.Sqrt,
+	"exp":   math.Exp,
+	"ln":    math.Log,
+	"log2":  math.Log2,
+	"log10": math.Log10,
+}
@@ -0,0 +1,554 @@
+package prometheus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lomik/graphite-clickhouse/helper/prompb"
+)
+
+// Subset of PromQL supported by embedded engine:
+//   - instant and range vector selectors: metric{label="value", label=~"regexp"}[5m] offset 1h
+//   - number literals and arithmetic + - * / with scalars and one-to-one vector matching
+//   - aggregations sum, avg, min, max, count with by (...) / without (...)
+//   - functions from rangeFunctions and instantFunctions
+
+type Expr interface{}
+
+type NumberLiteral struct {
+	Value float64
+}
+
+type VectorSelector struct {
+	Matchers []*prompb.LabelMatcher
+	Range    time.Duration // 0 for instant vector
+	Offset   time.Duration
+}
+
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+type AggregateExpr struct {
+	Op       string
+	Grouping []string
+	Without  bool
+	Expr     Expr
+}
+
+type BinaryExpr struct {
+	Op  byte
+	LHS Expr
+	RHS Expr
+}
+
+type ParenExpr struct {
+	Expr Expr
+}
+
+var aggregateOps = map[string]bool{
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+	"count": true,
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenDuration
+	tokenOp // punctuation and operators
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func lex(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	// inside [...] numbers are durations
+	inBrackets := false
+
+	for i := 0; i < len(input); {
+		c := input[i]
+
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentChar(input[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, input[start:i], start})
+		case (c >= '0' && c <= '9') || c == '.':
+			start := i
+			for i < len(input) && (isIdentChar(input[i]) || input[i] == '.' ||
+				((input[i] == '+' || input[i] == '-') && (input[i-1] == 'e' || input[i-1] == 'E') && !inBrackets)) {
+				i++
+			}
+			typ := tokenNumber
+			if inBrackets || (len(tokens) > 0 && tokens[len(tokens)-1].typ == tokenIdent && tokens[len(tokens)-1].val == "offset") {
+				typ = tokenDuration
+			}
+			tokens = append(tokens, token{typ, input[start:i], start})
+		case c == '"' || c == '\'' || c == '`':
+			start := i
+			i++
+			for i < len(input) && input[i] != c {
+				if input[i] == '\\' && c != '`' {
+					i++
+				}
+				i++
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			var s string
+			var err error
+			if c == '\'' {
+				s, err = strconv.Unquote(`"` + strings.Replace(strings.Replace(input[start+1:i-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`)
+			} else {
+				s, err = strconv.Unquote(input[start:i])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("malformed string at position %d: %s", start, err.Error())
+			}
+			tokens = append(tokens, token{tokenString, s, start})
+		default:
+			two := ""
+			if i+1 < len(input) {
+				two = input[i : i+2]
+			}
+			switch two {
+			case "!=", "=~", "!~", "==":
+				tokens = append(tokens, token{tokenOp, two, i})
+				i += 2
+				continue
+			}
+			if strings.IndexByte("{}()[],=+-*/", c) < 0 {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			if c == '[' {
+				inBrackets = true
+			}
+			if c == ']' {
+				inBrackets = false
+			}
+			tokens = append(tokens, token{tokenOp, string(c), i})
+			i++
+		}
+	}
+
+	tokens = append(tokens, token{tokenEOF, "", len(input)})
+	return tokens, nil
+}
+
+// ParseDuration parses prometheus duration like "5m", "1h30m", "1d"
+func ParseDuration(s string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+		"y":  365 * 24 * time.Hour,
+	}
+
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var d time.Duration
+	for len(s) > 0 {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("malformed duration %#v", s)
+		}
+		n, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		s = s[i:]
+
+		j := 0
+		for j < len(s) && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		unit, ok := units[s[:j]]
+		if !ok {
+			return 0, fmt.Errorf("unknown duration unit %#v", s[:j])
+		}
+		s = s[j:]
+
+		d += time.Duration(n) * unit
+	}
+
+	return d, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// ParseExpr parses PromQL expression
+func ParseExpr(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().typ != tokenEOF {
+		return nil, p.unexpected()
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.typ == tokenEOF {
+		return fmt.Errorf("unexpected end of input")
+	}
+	return fmt.Errorf("unexpected %#v at position %d", t.val, t.pos)
+}
+
+func (p *parser) isOp(val string) bool {
+	t := p.peek()
+	return t.typ == tokenOp && t.val == val
+}
+
+func (p *parser) expectOp(val string) error {
+	if !p.isOp(val) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().val[0]
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("*") || p.isOp("/") {
+		op := p.next().val[0]
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.isOp("-") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := e.(*NumberLiteral); ok {
+			n.Value = -n.Value
+			return n, nil
+		}
+		return &BinaryExpr{Op: '*', LHS: &NumberLiteral{Value: -1}, RHS: e}, nil
+	}
+	if p.isOp("+") {
+		p.next()
+		return p.parseUnary()
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parseGrouping() ([]string, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0)
+	for !p.isOp(")") {
+		t := p.next()
+		if t.typ != tokenIdent {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		labels = append(labels, t.val)
+		if p.isOp(",") {
+			p.next()
+		}
+	}
+	p.next()
+
+	return labels, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.peek()
+
+	switch {
+	case t.typ == tokenNumber:
+		p.next()
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed number %#v at position %d", t.val, t.pos)
+		}
+		return &NumberLiteral{Value: v}, nil
+	case t.typ == tokenOp && t.val == "(":
+		p.next()
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Expr: e}, nil
+	case t.typ == tokenOp && t.val == "{":
+		return p.parseSelector("")
+	case t.typ == tokenIdent && aggregateOps[t.val]:
+		return p.parseAggregate()
+	case t.typ == tokenIdent && p.tokens[p.pos+1].typ == tokenOp && p.tokens[p.pos+1].val == "(":
+		return p.parseCall()
+	case t.typ == tokenIdent:
+		p.next()
+		return p.parseSelector(t.val)
+	}
+
+	return nil, p.unexpected()
+}
+
+func (p *parser) parseAggregate() (Expr, error) {
+	agg := &AggregateExpr{Op: p.next().val}
+
+	parseModifier := func() error {
+		if p.peek().typ == tokenIdent && (p.peek().val == "by" || p.peek().val == "without") {
+			agg.Without = p.next().val == "without"
+			var err error
+			agg.Grouping, err = p.parseGrouping()
+			return err
+		}
+		return nil
+	}
+
+	if err := parseModifier(); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	agg.Expr = e
+
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+
+	if agg.Grouping == nil {
+		if err := parseModifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	return agg, nil
+}
+
+func (p *parser) parseCall() (Expr, error) {
+	name := p.next().val
+
+	_, isRange := rangeFunctions[name]
+	_, isInstant := instantFunctions[name]
+	if !isRange && !isInstant {
+		return nil, fmt.Errorf("unknown function %#v", name)
+	}
+
+	p.next() // (
+
+	call := &Call{Func: name, Args: make([]Expr, 0)}
+	for !p.isOp(")") {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, e)
+		if !p.isOp(")") {
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p.next()
+
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("function %#v expects 1 argument, got %d", name, len(call.Args))
+	}
+
+	if isRange {
+		if s, ok := call.Args[0].(*VectorSelector); !ok || s.Range == 0 {
+			return nil, fmt.Errorf("function %#v expects range vector argument", name)
+		}
+	}
+
+	return call, nil
+}
+
+func (p *parser) parseSelector(name string) (Expr, error) {
+	sel := &VectorSelector{Matchers: make([]*prompb.LabelMatcher, 0)}
+
+	if name != "" {
+		sel.Matchers = append(sel.Matchers, &prompb.LabelMatcher{
+			Type:  prompb.LabelMatcher_EQ,
+			Name:  "__name__",
+			Value: name,
+		})
+	}
+
+	if p.isOp("{") {
+		p.next()
+		for !p.isOp("}") {
+			l := p.next()
+			if l.typ != tokenIdent {
+				p.pos--
+				return nil, p.unexpected()
+			}
+
+			opToken := p.next()
+			var op prompb.LabelMatcher_Type
+			switch opToken.val {
+			case "=":
+				op = prompb.LabelMatcher_EQ
+			case "!=":
+				op = prompb.LabelMatcher_NEQ
+			case "=~":
+				op = prompb.LabelMatcher_RE
+			case "!~":
+				op = prompb.LabelMatcher_NRE
+			default:
+				p.pos--
+				return nil, p.unexpected()
+			}
+
+			v := p.next()
+			if v.typ != tokenString {
+				p.pos--
+				return nil, p.unexpected()
+			}
+
+			sel.Matchers = append(sel.Matchers, &prompb.LabelMatcher{Type: op, Name: l.val, Value: v.val})
+
+			if !p.isOp("}") {
+				if err := p.expectOp(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+		p.next()
+	}
+
+	if len(sel.Matchers) == 0 {
+		return nil, fmt.Errorf("vector selector must contain at least one matcher")
+	}
+
+	if p.isOp("[") {
+		p.next()
+		t := p.next()
+		if t.typ != tokenDuration {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		d, err := ParseDuration(t.val)
+		if err != nil {
+			return nil, err
+		}
+		sel.Range = d
+		if err := p.expectOp("]"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek().typ == tokenIdent && p.peek().val == "offset" {
+		p.next()
+		t := p.next()
+		if t.typ != tokenDuration {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		d, err := ParseDuration(t.val)
+		if err != nil {
+			return nil, err
+		}
+		sel.Offset = d
+	}
+
+	return sel, nil
+}
@@ -0,0 +1,143 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/helper/prompb"
+)
+
+func TestParseExpr(t *testing.T) {
+	assert := assert.New(t)
+
+	table := []struct {
+		query string
+		where string
+		isErr bool
+	}{
+		{`up`, "(Tag1='__name__=up')", false},
+		{`up{job="node", instance=~"host.*"}`, "(Tag1='__name__=up') AND (arrayExists((x) -> x='job=node', Tags)) AND (arrayExists((x) -> (x LIKE 'instance=host%') AND (match(x, 'instance=host.*')), Tags))", false},
+		{`{__name__="up",job!='node'}`, "(Tag1='__name__=up') AND (NOT arrayExists((x) -> x='job=node', Tags))", false},
+		{`up{job="node"`, "", true},
+		{`{}`, "", true},
+		{`up{job=node}`, "", true},
+	}
+
+	for _, test := range table {
+		expr, err := ParseExpr(test.query)
+		if test.isErr {
+			assert.Error(err, test.query)
+			continue
+		}
+		if !assert.NoError(err, test.query) {
+			continue
+		}
+
+		sel, ok := expr.(*VectorSelector)
+		if !assert.True(ok, test.query) {
+			continue
+		}
+
+		w, err := Where(sel.Matchers)
+		assert.NoError(err, test.query)
+		assert.Equal(test.where, w, test.query)
+	}
+
+	valid := []string{
+		`rate(http_requests_total{job="api"}[5m])`,
+		`sum by (job) (rate(http_requests_total[1h30m] offset 1d))`,
+		`sum(rate(http_requests_total[5m])) without (instance)`,
+		`max(up) - min(up) * 2 / -1.5e1`,
+		`abs(-up)`,
+	}
+	for _, q := range valid {
+		_, err := ParseExpr(q)
+		assert.NoError(err, q)
+	}
+
+	invalid := []string{
+		`rate(up)`,
+		`unknown_func(up)`,
+		`sum by (job (up)`,
+		`up[5x]`,
+		`up +`,
+	}
+	for _, q := range invalid {
+		_, err := ParseExpr(q)
+		assert.Error(err, q)
+	}
+}
+
+func TestEval(t *testing.T) {
+	assert := assert.New(t)
+
+	base := time.Unix(1000, 0)
+
+	// counter +60 every 60 seconds
+	makeSeries := func(job string, instance string, k float64) *prompb.TimeSeries {
+		ts := &prompb.TimeSeries{
+			Labels: []*prompb.Label{
+				{Name: "__name__", Value: "requests"},
+				{Name: "job", Value: job},
+				{Name: "instance", Value: instance},
+			},
+		}
+		for i := int64(0); i <= 20; i++ {
+			ts.Samples = append(ts.Samples, &prompb.Sample{Timestamp: (1000 + i*60) * 1000, Value: k * float64(i*60)})
+		}
+		return ts
+	}
+
+	fetch := func(ctx context.Context, matchers []*prompb.LabelMatcher, from, until int64) ([]*prompb.TimeSeries, error) {
+		return []*prompb.TimeSeries{
+			makeSeries("api", "a", 1),
+			makeSeries("api", "b", 2),
+			makeSeries("db", "c", 3),
+		}, nil
+	}
+
+	eval := func(query string, start, end time.Time, step time.Duration) *evalResult {
+		expr, err := ParseExpr(query)
+		assert.NoError(err, query)
+		res, err := newEvaluator(context.Background(), fetch, start, end, step).eval(expr)
+		assert.NoError(err, query)
+		return res
+	}
+
+	res := eval(`requests`, base.Add(10*time.Minute+30*time.Second), base.Add(10*time.Minute+30*time.Second), time.Second)
+	assert.Len(res.series, 3)
+	assert.Equal(600.0, res.series[0].values[0])
+	assert.Equal("requests", res.series[0].labels["__name__"])
+
+	// out of lookback delta
+	res = eval(`requests`, base.Add(time.Hour), base.Add(time.Hour), time.Second)
+	assert.True(math.IsNaN(res.series[0].values[0]))
+
+	res = eval(`rate(requests[5m])`, base.Add(10*time.Minute), base.Add(12*time.Minute), time.Minute)
+	assert.Len(res.series, 3)
+	for i := 0; i < 3; i++ {
+		assert.InDelta(1.0, res.series[0].values[i], 1e-9, fmt.Sprintf("step %d", i))
+		assert.InDelta(3.0, res.series[2].values[i], 1e-9, fmt.Sprintf("step %d", i))
+	}
+	_, hasName := res.series[0].labels["__name__"]
+	assert.False(hasName)
+
+	res = eval(`sum by (job) (rate(requests[5m]))`, base.Add(10*time.Minute), base.Add(10*time.Minute), time.Minute)
+	assert.Len(res.series, 2)
+	assert.Equal(map[string]string{"job": "api"}, res.series[0].labels)
+	assert.InDelta(3.0, res.series[0].values[0], 1e-9)
+	assert.InDelta(3.0, res.series[1].values[0], 1e-9)
+
+	res = eval(`count(requests) * 2 + 1`, base.Add(10*time.Minute), base.Add(10*time.Minute), time.Minute)
+	assert.Len(res.series, 1)
+	assert.Equal(7.0, res.series[0].values[0])
+
+	res = eval(`requests - requests`, base.Add(10*time.Minute), base.Add(10*time.Minute), time.Minute)
+	assert.Len(res.series, 3)
+	assert.Equal(0.0, res.series[0].values[0])
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -79,13 +80,13 @@ func (h *Handler) queryData(ctx context.Context, q *prompb.Query, metricList [][
 	fromTimestamp := q.StartTimestampMs / 1000
 	untilTimestamp := q.EndTimestampMs / 1000
 
-	pointsTable, _, rollupObj := render.SelectDataTable(h.config, fromTimestamp, untilTimestamp, []string{})
+	pointsTable, _, rollupObj, _ := render.SelectDataTable(h.config, fromTimestamp, untilTimestamp, []string{})
 
 	var maxStep uint32
-	listBuf := bytes.NewBuffer(nil)
+	paths := make([]string, 0, len(metricList))
 
-	// make Path IN (...), calculate max step
-	for index, m := range metricList {
+	// collect paths, calculate max step
+	for _, m := range metricList {
 		if len(m) == 0 {
 			continue
 		}
@@ -94,14 +95,10 @@ func (h *Handler) queryData(ctx context.Context, q *prompb.Query, metricList [][
 			maxStep = step
 		}
 
-		if index > 0 {
-			listBuf.WriteByte(',')
-		}
-
-		listBuf.WriteString("'" + clickhouse.Escape(unsafeString(m)) + "'")
+		paths = append(paths, unsafeString(m))
 	}
 
-	if listBuf.Len() == 0 {
+	if len(paths) == 0 {
 		// Return empty response
 		return &prompb.QueryResult{}, nil
 	}
@@ -113,8 +110,10 @@ func (h *Handler) queryData(ctx context.Context, q *prompb.Query, metricList [][
 		time.Unix(untilTimestamp, 0).Format("2006-01-02"),
 	)
 
+	pathIn, extData := render.PathIn(paths, h.config.ClickHouse.ExternalDataThreshold)
+
 	where := finder.NewWhere()
-	where.Andf("Path in (%s)", listBuf.String())
+	where.And(pathIn)
 
 	until := untilTimestamp - untilTimestamp%int64(maxStep) + int64(maxStep) - 1
 	where.Andf("Time >= %d AND Time <= %d", fromTimestamp, until)
@@ -133,13 +132,15 @@ func (h *Handler) queryData(ctx context.Context, q *prompb.Query, metricList [][
 		where.String(),
 	)
 
-	body, err := clickhouse.Reader(
-		ctx,
-		h.config.ClickHouse.Url,
-		query,
-		pointsTable,
-		clickhouse.Options{Timeout: h.config.ClickHouse.DataTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()},
-	)
+	opts := clickhouse.Options{Timeout: h.config.ClickHouse.DataTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()}
+
+	var body io.ReadCloser
+	var err error
+	if extData != nil {
+		body, err = clickhouse.ReaderWithExternalData(ctx, h.config.ClickHouse.Url, query, pointsTable, extData, opts)
+	} else {
+		body, err = clickhouse.Reader(ctx, h.config.ClickHouse.Url, query, pointsTable, opts)
+	}
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,372 @@
+package prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/RowBinary"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/metrics"
+	"github.com/lomik/graphite-clickhouse/helper/prompb"
+)
+
+// writeBatch contains RowBinary rows for points, tagged and series tables
+type writeBatch struct {
+	points      *bytes.Buffer
+	tagged      *bytes.Buffer
+	series      *bytes.Buffer
+	pointsCount int
+	newSeries   []string // paths with rows in tagged and series
+}
+
+func newWriteBatch() *writeBatch {
+	return &writeBatch{
+		points: new(bytes.Buffer),
+		tagged: new(bytes.Buffer),
+		series: new(bytes.Buffer),
+	}
+}
+
+func (b *writeBatch) append(other *writeBatch) {
+	b.points.Write(other.points.Bytes())
+	b.tagged.Write(other.tagged.Bytes())
+	b.series.Write(other.series.Bytes())
+	b.pointsCount += other.pointsCount
+	b.newSeries = append(b.newSeries, other.newSeries...)
+}
+
+var writeLostPoints = metrics.NewCounter("write_lost_points_total")
+
+// WriteHandler accepts prometheus remote write requests.
+// Requests are accepted into the bounded queue and uploaded to clickhouse in batches.
+// When the queue is full for queue-timeout the handler returns 503 with Retry-After.
+// 204 is returned when request is queued, not when it is written: points of batch which is not
+// uploaded after all retries are lost and only logged
+type WriteHandler struct {
+	config  *config.Config
+	logger  *zap.Logger
+	queue   chan *writeBatch // parsed requests
+	uploads chan *writeBatch // merged batches ready for upload
+	stop    chan struct{}
+	stopped sync.WaitGroup // batcher and uploaders
+
+	seriesMutex sync.Mutex
+	seriesDays  uint16          // day of seriesCache
+	seriesCache map[string]bool // paths with tagged and series rows uploaded (true) or being uploaded (false) today
+}
+
+func NewWriteHandler(config *config.Config) *WriteHandler {
+	h := &WriteHandler{
+		config:      config,
+		logger:      zapwriter.Logger("write"),
+		queue:       make(chan *writeBatch, config.PrometheusWrite.QueueSize),
+		uploads:     make(chan *writeBatch, config.PrometheusWrite.Threads),
+		stop:        make(chan struct{}),
+		seriesCache: make(map[string]bool),
+	}
+
+	h.stopped.Add(1 + config.PrometheusWrite.Threads)
+
+	go h.batcher()
+
+	for i := 0; i < config.PrometheusWrite.Threads; i++ {
+		go h.uploader()
+	}
+
+	return h
+}
+
+// Stop uploads queued requests and waits for uploaders. Must be called after http server is stopped
+func (h *WriteHandler) Stop() {
+	close(h.stop)
+	h.stopped.Wait()
+}
+
+// TaggedPath converts prometheus labels to tagged path "name?k1=v1&k2=v2" with sorted keys.
+// Returns empty path if labels has no __name__
+func TaggedPath(labels []*prompb.Label) (string, []string) {
+	var name string
+	tags := make(map[string]string, len(labels))
+
+	for _, l := range labels {
+		if l.Name == "__name__" {
+			name = l.Value
+		} else {
+			tags[l.Name] = l.Value
+		}
+	}
+
+	if name == "" {
+		return "", nil
+	}
+
+	return finder.TaggedPath(name, tags)
+}
+
+// isNewSeries returns true if tagged and series rows for path should be written today.
+// Path is skipped by next requests until rows are uploaded or upload failed, see markSeries
+func (h *WriteHandler) isNewSeries(path string, days uint16) bool {
+	h.seriesMutex.Lock()
+	defer h.seriesMutex.Unlock()
+
+	if h.seriesDays != days {
+		h.seriesDays = days
+		h.seriesCache = make(map[string]bool)
+	}
+
+	if _, ok := h.seriesCache[path]; ok {
+		return false
+	}
+
+	// series are written again after cache overflow
+	if len(h.seriesCache) >= h.config.PrometheusWrite.SeriesCacheSize {
+		h.seriesCache = make(map[string]bool)
+	}
+
+	h.seriesCache[path] = false
+	return true
+}
+
+// markSeries marks paths as written after successful upload. After failed upload paths are forgotten
+// and their rows are written again with next request
+func (h *WriteHandler) markSeries(paths []string, uploaded bool) {
+	h.seriesMutex.Lock()
+	defer h.seriesMutex.Unlock()
+
+	for _, path := range paths {
+		// cache is reset on the next day
+		if _, ok := h.seriesCache[path]; !ok {
+			continue
+		}
+		if uploaded {
+			h.seriesCache[path] = true
+		} else {
+			delete(h.seriesCache, path)
+		}
+	}
+}
+
+func (h *WriteHandler) makeBatch(req *prompb.WriteRequest) *writeBatch {
+	now := time.Now()
+	version := uint32(now.Unix())
+	today := RowBinary.DateToUint16(now)
+
+	b := newWriteBatch()
+	points := RowBinary.NewEncoder(b.points)
+	tagged := RowBinary.NewEncoder(b.tagged)
+	series := RowBinary.NewEncoder(b.series)
+
+	for _, ts := range req.Timeseries {
+		path, tags := TaggedPath(ts.Labels)
+		if path == "" {
+			continue
+		}
+
+		for _, s := range ts.Samples {
+			// skip stale markers
+			if math.IsNaN(s.Value) {
+				continue
+			}
+
+			t := time.Unix(s.Timestamp/1000, 0)
+
+			// INSERT INTO graphite (Path, Value, Time, Date, Timestamp)
+			points.String(path)
+			points.Float64(s.Value)
+			points.Uint32(uint32(t.Unix()))
+			points.Date(t)
+			points.Uint32(version)
+
+			b.pointsCount++
+		}
+
+		if !h.isNewSeries(path, today) {
+			continue
+		}
+		b.newSeries = append(b.newSeries, path)
+
+		if h.config.PrometheusWrite.TaggedTable != "" {
+			// INSERT INTO graphite_tagged (Date, Tag1, Path, Tags, Version)
+			for _, tag := range tags {
+				tagged.Uint16(today)
+				tagged.String(tag)
+				tagged.String(path)
+				tagged.StringList(tags)
+				tagged.Uint32(version)
+			}
+		}
+
+		if h.config.PrometheusWrite.SeriesTable != "" {
+			// INSERT INTO graphite_series (Date, Level, Path, Deleted, Version)
+			series.Uint16(today)
+			series.Uint32(uint32(strings.Count(path, ".") + 1))
+			series.String(path)
+			series.Uint8(0)
+			series.Uint32(version)
+		}
+	}
+
+	return b
+}
+
+// batcher merges small batches from queue and sends them to uploaders.
+// On stop queued batches are flushed and uploaders are finished
+func (h *WriteHandler) batcher() {
+	defer h.stopped.Done()
+
+	ticker := time.NewTicker(h.config.PrometheusWrite.FlushInterval.Value())
+	defer ticker.Stop()
+
+	current := newWriteBatch()
+
+	flush := func() {
+		if current.points.Len() == 0 && current.tagged.Len() == 0 && current.series.Len() == 0 {
+			return
+		}
+		// blocks if all uploaders are busy
+		h.uploads <- current
+		current = newWriteBatch()
+	}
+
+	for {
+		select {
+		case b := <-h.queue:
+			current.append(b)
+			if current.pointsCount >= h.config.PrometheusWrite.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-h.stop:
+			for {
+				select {
+				case b := <-h.queue:
+					current.append(b)
+				default:
+					flush()
+					close(h.uploads)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (h *WriteHandler) uploader() {
+	defer h.stopped.Done()
+
+	for b := range h.uploads {
+		if h.upload(b.points, h.config.PrometheusWrite.PointsTable, "(Path, Value, Time, Date, Timestamp)") != nil {
+			writeLostPoints.Add(uint64(b.pointsCount))
+		}
+		err := h.upload(b.tagged, h.config.PrometheusWrite.TaggedTable, "(Date, Tag1, Path, Tags, Version)")
+		if err == nil {
+			err = h.upload(b.series, h.config.PrometheusWrite.SeriesTable, "(Date, Level, Path, Deleted, Version)")
+		}
+		h.markSeries(b.newSeries, err == nil)
+	}
+}
+
+func (h *WriteHandler) upload(body *bytes.Buffer, table string, columns string) error {
+	if body.Len() == 0 || table == "" {
+		return nil
+	}
+
+	compressed := new(bytes.Buffer)
+	writer := gzip.NewWriter(compressed)
+	writer.Write(body.Bytes())
+	writer.Close()
+
+	query := fmt.Sprintf("INSERT INTO %s %s FORMAT RowBinary", table, columns)
+
+	var err error
+	for attempt := 0; attempt <= h.config.PrometheusWrite.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		_, err = clickhouse.PostGzip(
+			context.Background(),
+			h.config.ClickHouse.Url,
+			query,
+			table,
+			bytes.NewReader(compressed.Bytes()),
+			clickhouse.Options{
+				Timeout:        h.config.PrometheusWrite.Timeout.Value(),
+				ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value(),
+			},
+		)
+		if err == nil {
+			return nil
+		}
+	}
+
+	metrics.NewCounter("write_upload_errors_total", "table", table).Inc()
+	h.logger.Error("upload failed",
+		zap.String("table", table),
+		zap.Int("bytes", body.Len()),
+		zap.Error(err),
+	)
+	return err
+}
+
+func (h *WriteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	compressed, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reqBuf, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(reqBuf, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case <-h.stop:
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	b := h.makeBatch(&req)
+
+	timer := time.NewTimer(h.config.PrometheusWrite.QueueTimeout.Value())
+	defer timer.Stop()
+
+	select {
+	case h.queue <- b:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	// rows of batch are not written, write them with next request
+	h.markSeries(b.newSeries, false)
+
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "write queue is full", http.StatusServiceUnavailable)
+}
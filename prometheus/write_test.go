@@ -0,0 +1,299 @@
+package prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/metrics"
+	"github.com/lomik/graphite-clickhouse/helper/prompb"
+)
+
+func TestTaggedPath(t *testing.T) {
+	assert := assert.New(t)
+
+	path, tags := TaggedPath([]*prompb.Label{
+		{Name: "job", Value: "node"},
+		{Name: "__name__", Value: "up"},
+		{Name: "instance", Value: "host:9100"},
+	})
+
+	assert.Equal("up?instance=host%3A9100&job=node", path)
+	assert.Equal([]string{"__name__=up", "instance=host:9100", "job=node"}, tags)
+
+	// same format as read by TaggedFinder
+	f := finder.NewTagged("", "", clickhouse.Options{})
+	assert.Equal("up;instance=host:9100;job=node", string(f.Abs([]byte(path))))
+
+	labels, err := pathLabels(path)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"__name__": "up", "instance": "host:9100", "job": "node"}, labels)
+
+	path, _ = TaggedPath([]*prompb.Label{{Name: "job", Value: "node"}})
+	assert.Equal("", path)
+}
+
+func TestWriteHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.PrometheusWrite.TaggedTable = "graphite_tagged"
+	cfg.PrometheusWrite.SeriesTable = "graphite_series"
+	// flush by batch size only
+	cfg.PrometheusWrite.FlushInterval = &config.Duration{Duration: time.Hour}
+	cfg.PrometheusWrite.BatchSize = 4
+
+	h := NewWriteHandler(cfg)
+
+	req := &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "node"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1520056686000}, {Value: 0, Timestamp: 1520056701000}},
+			},
+		},
+	}
+
+	body, err := proto.Marshal(req)
+	assert.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/write", bytes.NewReader(snappy.Encode(nil, body))))
+		assert.Equal(http.StatusNoContent, w.Code)
+	}
+
+	for i := 0; i < 100 && len(srv.Requests()) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	requests := srv.Requests()
+	if !assert.Len(requests, 3) {
+		return
+	}
+
+	size := func(compressed []byte) int {
+		r, err := gzip.NewReader(bytes.NewReader(compressed))
+		assert.NoError(err)
+		b, err := ioutil.ReadAll(r)
+		assert.NoError(err)
+		return len(b)
+	}
+
+	pathLen := 1 + len("up?job=node")
+	// points: 2 requests * 2 samples
+	assert.Equal(4*(pathLen+8+4+2+4), size(requests[0].Query))
+	// tagged: written once for each tag
+	tagsLen := 1 + (1 + len("__name__=up")) + (1 + len("job=node"))
+	assert.Equal(2*(2+1+len("__name__=up")+pathLen+tagsLen+4)-len("__name__=up")+len("job=node"), size(requests[1].Query))
+	// series: written once
+	assert.Equal(2+4+pathLen+1+4, size(requests[2].Query))
+}
+
+func TestWriteHandlerRetrySeries(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var tagged []string
+	var failed bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.Contains(query, "graphite_tagged") {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		tagged = append(tagged, query)
+		if !failed {
+			failed = true
+			http.Error(w, "Code: 252. Too many parts", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.PrometheusWrite.TaggedTable = "graphite_tagged"
+	cfg.PrometheusWrite.FlushInterval = &config.Duration{Duration: time.Hour}
+	cfg.PrometheusWrite.BatchSize = 1
+	cfg.PrometheusWrite.Retries = 0
+
+	h := NewWriteHandler(cfg)
+
+	body, err := proto.Marshal(&prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1520056686000}},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(tagged)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/write", bytes.NewReader(snappy.Encode(nil, body))))
+		assert.Equal(http.StatusNoContent, w.Code)
+
+		// wait for upload of the first two requests
+		for j := 0; j < 100 && i < 2 && count() <= i; j++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	h.Stop()
+
+	// tagged rows are written again after failed upload and not written after successful one
+	assert.Equal(2, count())
+}
+
+func TestWriteHandlerQueueFull(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.New()
+	cfg.PrometheusWrite.TaggedTable = "graphite_tagged"
+	cfg.PrometheusWrite.QueueTimeout = &config.Duration{Duration: 10 * time.Millisecond}
+
+	// queue without batcher is always full
+	h := &WriteHandler{
+		config:      cfg,
+		queue:       make(chan *writeBatch),
+		stop:        make(chan struct{}),
+		seriesCache: make(map[string]bool),
+	}
+
+	body, err := proto.Marshal(&prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1520056686000}},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/write", bytes.NewReader(snappy.Encode(nil, body))))
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	assert.Equal("1", w.Header().Get("Retry-After"))
+
+	// rejected series is written with next request
+	assert.Empty(h.seriesCache)
+}
+
+func TestWriteHandlerStop(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.PrometheusWrite.FlushInterval = &config.Duration{Duration: time.Hour}
+
+	h := NewWriteHandler(cfg)
+
+	body, err := proto.Marshal(&prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1520056686000}},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/write", bytes.NewReader(snappy.Encode(nil, body))))
+	assert.Equal(http.StatusNoContent, w.Code)
+
+	// queued request is uploaded on stop
+	h.Stop()
+	assert.Len(srv.Requests(), 1)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/write", bytes.NewReader(snappy.Encode(nil, body))))
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+func TestWriteHandlerSeriesCacheSize(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.New()
+	cfg.PrometheusWrite.SeriesCacheSize = 2
+
+	h := &WriteHandler{config: cfg, seriesCache: make(map[string]bool)}
+
+	assert.True(h.isNewSeries("a", 1))
+	assert.True(h.isNewSeries("b", 1))
+	assert.False(h.isNewSeries("a", 1))
+	assert.Len(h.seriesCache, 2)
+
+	// cache is cleared on overflow
+	assert.True(h.isNewSeries("c", 1))
+	assert.Len(h.seriesCache, 1)
+	assert.True(h.isNewSeries("a", 1))
+}
+
+func TestWriteHandlerLostPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 252. Too many parts", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.PrometheusWrite.FlushInterval = &config.Duration{Duration: time.Hour}
+	cfg.PrometheusWrite.Retries = 0
+
+	h := NewWriteHandler(cfg)
+
+	body, err := proto.Marshal(&prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1520056686000}, {Value: 2, Timestamp: 1520056701000}},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	lost := writeLostPoints.Value()
+	errors := metrics.NewCounter("write_upload_errors_total", "table", "graphite").Value()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/write", bytes.NewReader(snappy.Encode(nil, body))))
+	assert.Equal(http.StatusNoContent, w.Code)
+
+	h.Stop()
+
+	assert.Equal(lost+2, writeLostPoints.Value())
+	assert.Equal(errors+1, metrics.NewCounter("write_upload_errors_total", "table", "graphite").Value())
+}
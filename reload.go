@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/config"
+)
+
+// reloadableHandler serves requests with handler created from the current config
+type reloadableHandler struct {
+	handler atomic.Value // http.Handler
+	create  func(cfg *config.Config) http.Handler
+}
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// Reloader re-reads config file and replaces handlers with new ones created from new config.
+// Requests in progress are finished with the old config
+type Reloader struct {
+	sync.Mutex
+	filename string
+	config   atomic.Value // *config.Config
+	handlers []*reloadableHandler
+}
+
+func NewReloader(filename string, cfg *config.Config) *Reloader {
+	r := &Reloader{filename: filename}
+	r.config.Store(cfg)
+	return r
+}
+
+// Config returns current config
+func (r *Reloader) Config() *config.Config {
+	return r.config.Load().(*config.Config)
+}
+
+// Handler returns handler recreated from config on each reload
+func (r *Reloader) Handler(create func(cfg *config.Config) http.Handler) http.Handler {
+	r.Lock()
+	defer r.Unlock()
+
+	h := &reloadableHandler{create: create}
+	h.handler.Store(create(r.Config()))
+	r.handlers = append(r.handlers, h)
+
+	return h
+}
+
+// Reload reads and validates config. The old config is kept if new one is invalid
+func (r *Reloader) Reload() error {
+	r.Lock()
+	defer r.Unlock()
+
+	logger := zapwriter.Logger("reload")
+
+	newConfig, err := config.ReadConfig(r.filename)
+	if err != nil {
+		logger.Error("config is invalid, old config is kept", zap.String("file", r.filename), zap.Error(err))
+		return err
+	}
+
+	oldConfig := r.Config()
+
+	diff := config.Diff(oldConfig, newConfig)
+	for _, line := range diff {
+		logger.Info("config changed", zap.String("diff", line))
+	}
+
+	// keep cached data if cache size and source of data are not changed
+	if !dataChanged(diff) {
+		if newConfig.FindCache.MaxSize == oldConfig.FindCache.MaxSize {
+			newConfig.FindCache.Cache = oldConfig.FindCache.Cache
+		}
+		if newConfig.RenderCache.MaxSize == oldConfig.RenderCache.MaxSize {
+			newConfig.RenderCache.Cache = oldConfig.RenderCache.Cache
+		}
+	}
+
+	if newConfig.Common.Listen != oldConfig.Common.Listen ||
+		newConfig.Common.MaxCPU != oldConfig.Common.MaxCPU ||
+		newConfig.Common.MetricEndpoint != oldConfig.Common.MetricEndpoint ||
+		!reflect.DeepEqual(newConfig.PrometheusWrite, oldConfig.PrometheusWrite) ||
+		!reflect.DeepEqual(newConfig.Logging, oldConfig.Logging) {
+		logger.Warn("changes of listen, max-cpu, metric-endpoint, prometheus-write and logging are applied after restart")
+	}
+
+	for _, h := range r.handlers {
+		h.handler.Store(h.create(newConfig))
+	}
+	r.config.Store(newConfig)
+
+	logger.Info("config reloaded", zap.String("file", r.filename))
+	return nil
+}
+
+// dataKeys are prefixes of config keys which change results of find and render: blacklist, tables, prefix,
+// rollup and carbonlink
+var dataKeys = []string{
+	"common.target-blacklist ",
+	"clickhouse.",
+	"data-table",
+	"rollup ",
+	"carbonlink.",
+}
+
+// dataChanged returns true if config diff changes any of dataKeys, so cached find and render results are stale
+func dataChanged(diff []string) bool {
+	for _, line := range diff {
+		key := strings.TrimLeft(line, "+- ")
+		for _, prefix := range dataKeys {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HandleSignals reloads config on SIGHUP
+func (r *Reloader) HandleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+
+	go func() {
+		for range c {
+			r.Reload()
+		}
+	}()
+}
+
+// allowed returns true if request has reload-token or comes from localhost if token is not configured
+func (r *Reloader) allowed(req *http.Request) bool {
+	token := r.Config().Common.ReloadToken
+	if token != "" {
+		auth := req.Header.Get("Authorization")
+		return strings.HasPrefix(auth, "Bearer ") &&
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServeHTTP reloads config on authorized POST request
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !r.allowed(req) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := r.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("OK\n"))
+}
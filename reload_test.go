@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+)
+
+const testRollup = `
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`
+
+func writeTestConfig(t *testing.T, dir string, common string, clickhouse string) string {
+	filename := filepath.Join(dir, "graphite-clickhouse.conf")
+	body := fmt.Sprintf(`
+[common]
+reload-token = "secret"
+%s
+
+[clickhouse]
+rollup-conf = "%s"
+%s
+
+[find-cache]
+max-size = 1024
+
+[render-cache]
+max-size = 1024
+
+[[logging]]
+logger = ""
+file = "stderr"
+level = "error"
+`, common, filepath.Join(dir, "rollup.xml"), clickhouse)
+
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(body), 0644))
+	return filename
+}
+
+func newTestReloader(t *testing.T) (*Reloader, string, func()) {
+	dir, err := ioutil.TempDir("", "graphite-clickhouse")
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rollup.xml"), []byte(testRollup), 0644))
+	filename := writeTestConfig(t, dir, "", "")
+
+	cfg, err := config.ReadConfig(filename)
+	assert.NoError(t, err)
+
+	return NewReloader(filename, cfg), dir, func() { os.RemoveAll(dir) }
+}
+
+type configHandler struct {
+	config *config.Config
+}
+
+func (h *configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func TestReload(t *testing.T) {
+	assert := assert.New(t)
+
+	r, dir, cleanup := newTestReloader(t)
+	defer cleanup()
+
+	handler := r.Handler(func(cfg *config.Config) http.Handler { return &configHandler{config: cfg} })
+	current := func() *config.Config {
+		return handler.(*reloadableHandler).handler.Load().(*configHandler).config
+	}
+
+	oldConfig := r.Config()
+	assert.True(oldConfig == current())
+
+	// handlers are recreated, caches are kept
+	assert.NoError(r.Reload())
+	assert.False(oldConfig == r.Config())
+	assert.True(r.Config() == current())
+	assert.True(oldConfig.FindCache.Cache == r.Config().FindCache.Cache)
+	assert.True(oldConfig.RenderCache.Cache == r.Config().RenderCache.Cache)
+
+	// caches are purged with changed source of data
+	oldConfig = r.Config()
+	writeTestConfig(t, dir, "", `data-table = "graphite_archive"`)
+	assert.NoError(r.Reload())
+	assert.Equal("graphite_archive", current().ClickHouse.DataTable)
+	assert.False(oldConfig.FindCache.Cache == r.Config().FindCache.Cache)
+	assert.False(oldConfig.RenderCache.Cache == r.Config().RenderCache.Cache)
+
+	// caches are purged with changed blacklist
+	oldConfig = r.Config()
+	writeTestConfig(t, dir, `target-blacklist = ["^secret\\."]`, `data-table = "graphite_archive"`)
+	assert.NoError(r.Reload())
+	assert.Len(current().Common.Blacklist, 1)
+	assert.False(oldConfig.FindCache.Cache == r.Config().FindCache.Cache)
+	assert.False(oldConfig.RenderCache.Cache == r.Config().RenderCache.Cache)
+
+	// caches are purged with changed rollup
+	oldConfig = r.Config()
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "rollup.xml"), []byte(testRollup+"\n<!-- -->"), 0644))
+	assert.NoError(r.Reload())
+	assert.True(oldConfig.RenderCache.Cache == r.Config().RenderCache.Cache)
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "rollup.xml"), []byte(`
+<graphite_rollup>
+	<default>
+		<function>max</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`), 0644))
+	assert.NoError(r.Reload())
+	assert.False(oldConfig.RenderCache.Cache == r.Config().RenderCache.Cache)
+
+	// invalid config is not applied
+	oldConfig = r.Config()
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "graphite-clickhouse.conf"), []byte("[clickhouse"), 0644))
+	assert.Error(r.Reload())
+	assert.True(oldConfig == r.Config())
+	assert.True(oldConfig == current())
+}
+
+func TestReloadHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	r, _, cleanup := newTestReloader(t)
+	defer cleanup()
+
+	reload := func(method string, remoteAddr string, auth string) int {
+		req := httptest.NewRequest(method, "/admin/reload", nil)
+		req.RemoteAddr = remoteAddr
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(http.StatusMethodNotAllowed, reload("GET", "10.0.0.1:1234", "Bearer secret"))
+	assert.Equal(http.StatusForbidden, reload("POST", "10.0.0.1:1234", ""))
+	assert.Equal(http.StatusForbidden, reload("POST", "127.0.0.1:1234", "Bearer wrong"))
+	assert.Equal(http.StatusOK, reload("POST", "10.0.0.1:1234", "Bearer secret"))
+
+	// without token reload is allowed from localhost only
+	r.Config().Common.ReloadToken = ""
+	assert.Equal(http.StatusForbidden, reload("POST", "10.0.0.1:1234", "Bearer secret"))
+	assert.Equal(http.StatusOK, reload("POST", "127.0.0.1:1234", ""))
+	r.Config().Common.ReloadToken = ""
+	assert.Equal(http.StatusOK, reload("POST", "[::1]:1234", ""))
+}
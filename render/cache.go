@@ -0,0 +1,73 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lomik/graphite-clickhouse/helper/log"
+)
+
+// renderCacheKey returns key of fetched data for sorted targets, from, until and points table.
+// from and until must be aligned to step as in data query
+func renderCacheKey(targets []string, from int64, until int64, table string, maxDataPoints int) string {
+	sorted := make([]string, len(targets))
+	copy(sorted, targets)
+	sort.Strings(sorted)
+
+	return fmt.Sprintf("%s;%d;%d;%s;%d", strings.Join(sorted, "&"), from, until, table, maxDataPoints)
+}
+
+// copy returns Data with own copy of points, Reply modifies points during rollup
+func (d *Data) copy() *Data {
+	return &Data{
+		Points: d.Points.Copy(),
+	}
+}
+
+// size returns approximate memory size of Data in bytes
+func (d *Data) size() int64 {
+	size := int64(d.Points.Len()) * 24
+
+	var metricID uint32
+	for _, p := range d.Points.List() {
+		if p.MetricID != metricID {
+			metricID = p.MetricID
+			size += int64(len(d.Points.MetricName(metricID))) + 64
+		}
+	}
+
+	return size
+}
+
+// cachedFetch returns data from render cache. Data is fetched once for concurrent requests with same key.
+// Shared fetch is not canceled with request which started it, it is limited by data-timeout
+func (h *Handler) cachedFetch(ctx context.Context, key string, fetch func(ctx context.Context) (*Data, error)) (*Data, error) {
+	c := h.config.RenderCache.Cache
+
+	if v, ok := c.Get(key); ok {
+		return v.(*Data).copy(), nil
+	}
+
+	v, err, _ := h.group.Do(key, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(
+			context.WithValue(context.Background(), "logger", log.FromContext(ctx)),
+			h.config.ClickHouse.DataTimeout.Value(),
+		)
+		defer cancel()
+
+		data, err := fetch(fetchCtx)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(key, data, data.size()+int64(len(key)), h.config.RenderCache.TTL.Value())
+		return data, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Data).copy(), nil
+}
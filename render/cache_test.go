@@ -0,0 +1,130 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/cache"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestRenderCacheKey(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(
+		renderCacheKey([]string{"b.*", "a.*"}, 1000, 2000, "graphite", 0),
+		renderCacheKey([]string{"a.*", "b.*"}, 1000, 2000, "graphite", 0),
+	)
+	assert.NotEqual(
+		renderCacheKey([]string{"a.*"}, 1000, 2000, "graphite", 0),
+		renderCacheKey([]string{"a.*"}, 1010, 2000, "graphite", 0),
+	)
+	assert.NotEqual(
+		renderCacheKey([]string{"a.*"}, 1000, 2000, "graphite", 0),
+		renderCacheKey([]string{"a.*"}, 1000, 2030, "graphite", 0),
+	)
+	assert.NotEqual(
+		renderCacheKey([]string{"a.*"}, 1000, 2000, "graphite", 0),
+		renderCacheKey([]string{"a.*"}, 1000, 2000, "graphite_archive", 0),
+	)
+}
+
+func TestRenderCache(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 550
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\n")
+		}
+		return makeData([]testPoint{
+			{"a.b.c", 1, from, 10},
+			{"a.b.c", 3, from + 30, 10},
+			{"a.b.c", 4, from + 60, 10},
+		})
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.Rollup = r
+	cfg.RenderCache.Cache = cache.New(1024 * 1024)
+
+	h := NewHandler(cfg)
+
+	render := func(shift uint32) string {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=a.b.*&from=%d&until=%d&format=json", from+shift, until+shift), nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		assert.Equal(200, w.Code)
+		return w.Body.String()
+	}
+
+	// cached points are not modified by rollup in reply
+	expected := render(0)
+	assert.Contains(expected, `"datapoints":[[2,1520056800],[4,1520056860]`)
+	assert.Equal(expected, render(0))
+
+	// request shifted by seconds shares data aligned to step, reply is cut to requested interval
+	assert.Contains(render(5), `"datapoints":[[4,1520056860]`)
+
+	dataQueries := 0
+	for _, req := range srv.Requests() {
+		if bytes.Contains(req.Query, []byte("PREWHERE")) {
+			dataQueries++
+		}
+	}
+	assert.Equal(1, dataQueries)
+
+	stats := cfg.RenderCache.Cache.Stats()
+	assert.Equal(uint64(2), stats.Hits)
+	assert.Equal(uint64(1), stats.Misses)
+}
+
+func TestCachedFetchDetached(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.New()
+	cfg.RenderCache.Cache = cache.New(1024 * 1024)
+	h := NewHandler(cfg)
+
+	// request which started shared fetch is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	data, err := h.cachedFetch(ctx, "key", func(ctx context.Context) (*Data, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, fmt.Errorf("fetch without data-timeout")
+		}
+		return EmptyData, nil
+	})
+	assert.NoError(err)
+	assert.NotNil(data)
+}
@@ -11,6 +11,7 @@ import (
 	"unsafe"
 
 	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
 )
 
 func unsafeString(b []byte) string {
@@ -57,14 +58,20 @@ func ReadUvarint(array []byte) (uint64, int, error) {
 }
 
 type Data struct {
-	body    []byte // raw RowBinary from clickhouse
-	Points  *point.Points
-	nameMap map[string]string
-	Aliases map[string][]string
+	body          []byte // raw RowBinary from clickhouse
+	Points        *point.Points
+	nameMap       map[string]string
+	Aliases       map[string][]string
+	MaxDataPoints int // consolidate points to maxDataPoints in reply if > 0
 }
 
 var EmptyData *Data = &Data{Points: point.NewPoints()}
 
+// rollupMetric rolls up points of one metric and consolidates them to MaxDataPoints
+func (d *Data) rollupMetric(rollupObj *rollup.Rollup, from, until uint32, points []point.Point) ([]point.Point, uint32) {
+	return rollupObj.RollupMetricMaxDataPoints(d.Points.MetricName(points[0].MetricID), from, until, d.MaxDataPoints, points)
+}
+
 func (d *Data) finalName(name string) string {
 	s, ok := d.nameMap[name]
 	if !ok {
@@ -106,6 +113,28 @@ func DataSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err erro
 	return tokenLen, data[:tokenLen], nil
 }
 
+// parseRow decodes row with Path, Time, Value and Timestamp splitted by DataSplitFunc
+func parseRow(row []byte) (name []byte, time uint32, value float64, timestamp uint32, err error) {
+	namelen, readBytes, err := ReadUvarint(row)
+	if err != nil {
+		return nil, 0, 0, 0, errClickHouseResponse
+	}
+	row = row[int(readBytes):]
+
+	name = row[:int(namelen)]
+	row = row[int(namelen):]
+
+	time = binary.LittleEndian.Uint32(row[:4])
+	row = row[4:]
+
+	value = math.Float64frombits(binary.LittleEndian.Uint64(row[:8]))
+	row = row[8:]
+
+	timestamp = binary.LittleEndian.Uint32(row[:4])
+
+	return name, time, value, timestamp, nil
+}
+
 func DataParse(bodyReader io.Reader, extraPoints *point.Points, isReverse bool) (*Data, error) {
 	d := &Data{
 		Points: point.NewPoints(),
@@ -115,15 +144,7 @@ func DataParse(bodyReader io.Reader, extraPoints *point.Points, isReverse bool)
 
 	// add extraPoints. With NameToID
 	if extraPoints != nil {
-		extraList := extraPoints.List()
-		for i := 0; i < len(extraList); i++ {
-			pp.AppendPoint(
-				pp.MetricID(extraPoints.MetricName(extraList[i].MetricID)),
-				extraList[i].Value,
-				extraList[i].Time,
-				extraList[i].Timestamp,
-			)
-		}
+		pp.Merge(extraPoints)
 	}
 
 	nameBuf := make([]byte, 65536)
@@ -135,16 +156,10 @@ func DataParse(bodyReader io.Reader, extraPoints *point.Points, isReverse bool)
 	scanner.Split(DataSplitFunc)
 
 	for scanner.Scan() {
-		row := scanner.Bytes()
-
-		namelen, readBytes, err := ReadUvarint(row)
+		newName, time, value, timestamp, err := parseRow(scanner.Bytes())
 		if err != nil {
-			return nil, errClickHouseResponse
+			return nil, err
 		}
-		row = row[int(readBytes):]
-
-		newName := row[:int(namelen)]
-		row = row[int(namelen):]
 
 		if bytes.Compare(newName, name) != 0 {
 			if len(newName) > len(nameBuf) {
@@ -161,14 +176,6 @@ func DataParse(bodyReader io.Reader, extraPoints *point.Points, isReverse bool)
 			}
 		}
 
-		time := binary.LittleEndian.Uint32(row[:4])
-		row = row[4:]
-
-		value := math.Float64frombits(binary.LittleEndian.Uint64(row[:8]))
-		row = row[8:]
-
-		timestamp := binary.LittleEndian.Uint32(row[:4])
-
 		pp.AppendPoint(metricID, value, time, timestamp)
 	}
 
@@ -7,7 +7,8 @@ import (
 	"github.com/lomik/graphite-clickhouse/helper/rollup"
 )
 
-func SelectDataTable(cfg *config.Config, from int64, until int64, targets []string) (string, bool, *rollup.Rollup) {
+// SelectDataTable returns points table, reverse flag, rollup rules and rollup-pushdown flag for request
+func SelectDataTable(cfg *config.Config, from int64, until int64, targets []string) (string, bool, *rollup.Rollup, bool) {
 	now := time.Now().Unix()
 
 TableLoop:
@@ -55,11 +56,11 @@ TableLoop:
 		}
 
 		if t.Rollup != nil {
-			return t.Table, t.Reverse, t.Rollup
+			return t.Table, t.Reverse, t.Rollup, t.RollupPushdown
 		} else {
-			return t.Table, t.Reverse, cfg.Rollup
+			return t.Table, t.Reverse, cfg.Rollup, t.RollupPushdown
 		}
 	}
 
-	return cfg.ClickHouse.DataTable, false, cfg.Rollup
+	return cfg.ClickHouse.DataTable, false, cfg.Rollup, false
 }
@@ -0,0 +1,348 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-graphite/carbonapi/pkg/parser"
+	pickle "github.com/lomik/graphite-pickle"
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/log"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+// exprFunc evaluates graphite function call
+type exprFunc func(ctx *evalContext, e parser.Expr) ([]*series, error)
+
+// exprFunctions is filled in init() because functions evaluate their arguments with evalContext.eval
+var exprFunctions map[string]exprFunc
+
+// IsExprTarget returns true if target contains graphite function which must be evaluated by graphite-clickhouse.
+// seriesByTag is not evaluated, it is resolved by finder
+func IsExprTarget(target string) bool {
+	e, tail, err := parser.Parse(target)
+	if err != nil || tail != "" {
+		return false
+	}
+	return e.IsFunc() && e.Target() != "seriesByTag"
+}
+
+// leafTarget returns target resolved by finder for name or seriesByTag expression
+func leafTarget(e parser.Expr) string {
+	if e.IsFunc() {
+		return e.Target() + "(" + e.RawArgs() + ")"
+	}
+	return e.Target()
+}
+
+// leafTargets appends metric names and seriesByTag calls of expression to list
+func leafTargets(e parser.Expr, list []string) []string {
+	switch {
+	case e.IsName():
+		// boolean arguments are parsed as names
+		switch e.Target() {
+		case "true", "false", "True", "False":
+			return list
+		}
+		return append(list, leafTarget(e))
+	case e.IsFunc() && e.Target() == "seriesByTag":
+		return append(list, leafTarget(e))
+	case e.IsFunc():
+		for _, arg := range e.Args() {
+			list = leafTargets(arg, list)
+		}
+	}
+	return list
+}
+
+// exprLookback returns how many seconds before from should be fetched to evaluate expression.
+// movingAverage with interval window requires data before from
+func exprLookback(e parser.Expr) uint32 {
+	if !e.IsFunc() {
+		return 0
+	}
+
+	var lookback uint32
+	for _, arg := range e.Args() {
+		if l := exprLookback(arg); l > lookback {
+			lookback = l
+		}
+	}
+
+	if e.Target() == "movingAverage" && len(e.Args()) > 1 && e.Args()[1].IsString() {
+		window, err := e.GetIntervalArg(1, 1)
+		if err == nil && window > 0 {
+			lookback += uint32(window)
+		}
+	}
+
+	return lookback
+}
+
+// evalContext contains fetched series of leaf targets
+type evalContext struct {
+	leaves map[string][]*series
+}
+
+func (ctx *evalContext) eval(e parser.Expr) ([]*series, error) {
+	switch {
+	case e.IsName(), e.IsFunc() && e.Target() == "seriesByTag":
+		return ctx.leaves[leafTarget(e)], nil
+	case e.IsFunc():
+		f, ok := exprFunctions[e.Target()]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %#v", e.Target())
+		}
+		return f(ctx, e)
+	}
+	return nil, fmt.Errorf("series expected, got %#v", e.StringValue())
+}
+
+// evalArgs evaluates all arguments of expression starting from n as series lists
+func (ctx *evalContext) evalArgs(e parser.Expr, n int) ([]*series, error) {
+	args := e.Args()
+	if len(args) <= n {
+		return nil, parser.ErrMissingTimeseries
+	}
+
+	var result []*series
+	for _, arg := range args[n:] {
+		if !arg.IsName() && !arg.IsFunc() {
+			continue
+		}
+		list, err := ctx.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, list...)
+	}
+	return result, nil
+}
+
+// renderExpr fetches series of leaf targets and evaluates graphite functions of targets.
+// Plain targets are not parsed, they are resolved by finder as in render without expressions
+func (h *Handler) renderExpr(w http.ResponseWriter, r *http.Request, targets []string, fromTimestamp int64, untilTimestamp int64, maxDataPoints int) {
+	exprs := make([]parser.Expr, len(targets)) // nil for plain targets
+	leaves := make([]string, 0)
+	var lookback uint32
+
+	for i, target := range targets {
+		if !IsExprTarget(target) {
+			leaves = append(leaves, target)
+			continue
+		}
+		e, _, err := parser.Parse(target)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request (malformed target %#v: %s)", target, err.Error()), http.StatusBadRequest)
+			return
+		}
+		exprs[i] = e
+		leaves = leafTargets(e, leaves)
+		if l := exprLookback(e); l > lookback {
+			lookback = l
+		}
+	}
+
+	// find each leaf once
+	uniq := make(map[string]bool)
+	leafList := make([]string, 0, len(leaves))
+	for _, t := range leaves {
+		if !uniq[t] {
+			uniq[t] = true
+			leafList = append(leafList, t)
+		}
+	}
+
+	fetchFrom := fromTimestamp - int64(lookback)
+
+	aliases, err := findTargets(r.Context(), h.config, leafList, fetchFrom, untilTimestamp, h.config.ClickHouse.FindConcurrency)
+	if err != nil {
+		http.Error(w, err.Error(), finder.HTTPStatus(err))
+		return
+	}
+
+	// series of leaf targets
+	leafPaths := make(map[string][]string)
+	for path, a := range aliases {
+		for k := 0; k < len(a); k += 2 {
+			leafPaths[a[k+1]] = append(leafPaths[a[k+1]], path)
+		}
+	}
+
+	// aggregated series are read by clickhouse anyway
+	if err := finder.CheckLimit("max-metrics-per-render", len(aliases), h.config.Limits.MaxMetricsPerRender); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// aggregations of large targets are evaluated by clickhouse
+	pushdowns := make([]*aggregationPushdown, len(exprs))
+	pointsTable, isReverse, rollupObj, _ := SelectDataTable(h.config, fetchFrom, untilTimestamp, leafList)
+	var pointsCount int
+	for i, e := range exprs {
+		if e == nil {
+			continue
+		}
+		p := parsePushdown(e)
+		if p == nil {
+			continue
+		}
+		paths := leafPaths[p.leaf]
+		if !h.canPushdown(p, paths, rollupObj, isReverse) {
+			continue
+		}
+		sort.Strings(paths)
+		pushdowns[i] = p
+
+		step := rollupObj.Step(paths[0], uint32(fetchFrom))
+		pointsCount += len(paths) * (int((untilTimestamp-fetchFrom)/int64(step)) + 1)
+	}
+
+	if err := finder.CheckLimit("max-points-per-render", pointsCount, h.config.Limits.MaxPointsPerRender); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pushed := make([][]*series, len(exprs))
+	for i, p := range pushdowns {
+		if p == nil {
+			continue
+		}
+		list, err := h.fetchPushdown(r.Context(), p, leafPaths[p.leaf], pointsTable, isReverse, rollupObj, fetchFrom, untilTimestamp)
+		if err != nil {
+			http.Error(w, err.Error(), finder.HTTPStatus(err))
+			return
+		}
+		pushed[i] = list
+	}
+
+	// fetch points of leaves used by other expressions
+	needed := make(map[string]bool)
+	fetchTargets := make([]string, 0, len(leafList))
+	for i, e := range exprs {
+		if pushed[i] != nil {
+			continue
+		}
+		list := []string{targets[i]}
+		if e != nil {
+			list = leafTargets(e, nil)
+		}
+		for _, t := range list {
+			if !needed[t] {
+				needed[t] = true
+				fetchTargets = append(fetchTargets, t)
+			}
+		}
+	}
+
+	fetchAliases := make(map[string][]string)
+	for path, a := range aliases {
+		for k := 0; k < len(a); k += 2 {
+			if needed[a[k+1]] {
+				fetchAliases[path] = append(fetchAliases[path], a[k], a[k+1])
+			}
+		}
+	}
+
+	data, rollupObj, ok := h.renderAliases(w, r, fetchTargets, fetchAliases, fetchFrom, untilTimestamp, 0, false)
+	if !ok {
+		return
+	}
+
+	evalStart := time.Now()
+
+	ctx := &evalContext{
+		leaves: seriesFromData(data, rollupObj, uint32(fetchFrom), uint32(untilTimestamp)),
+	}
+
+	// paths of series by name for rollup function of plain targets
+	paths := make(map[string]string)
+	for path, a := range fetchAliases {
+		for k := 0; k < len(a); k += 2 {
+			paths[a[k]] = path
+		}
+	}
+
+	result := make([]*series, 0)
+	for i, e := range exprs {
+		var list []*series
+		switch {
+		case pushed[i] != nil:
+			list = pushed[i]
+		case e == nil:
+			list = ctx.leaves[targets[i]]
+		default:
+			list, err = ctx.eval(e)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if maxDataPoints <= 0 {
+			result = append(result, list...)
+			continue
+		}
+
+		// series of plain targets are consolidated with rollup function like in render without expressions.
+		// Series derived by functions are consolidated with average like in graphite-web
+		for _, s := range list {
+			step := rollup.MaxDataPointsStep(s.step, uint32(fromTimestamp), uint32(untilTimestamp), maxDataPoints)
+			if step == s.step {
+				result = append(result, s)
+				continue
+			}
+			aggr := aggregateAvg
+			if path, ok := paths[s.name]; ok && e == nil {
+				aggr = rollupAggregateFunc(rollupObj.Match(path).Function)
+			}
+			result = append(result, s.consolidate(step, aggr))
+		}
+	}
+
+	d := time.Since(evalStart)
+	log.FromContext(r.Context()).Debug("eval", zap.String("runtime", d.String()), zap.Duration("runtime_ns", d))
+
+	h.replySeries(w, r, result, uint32(fromTimestamp), uint32(untilTimestamp))
+}
+
+// replySeries writes evaluated series in requested format
+func (h *Handler) replySeries(w http.ResponseWriter, r *http.Request, list []*series, from, until uint32) {
+	format := r.FormValue("format")
+
+	if format == "pickle" && len(list) == 0 {
+		w.Write(pickle.EmptyList)
+		return
+	}
+
+	writer := bufio.NewWriterSize(w, 1024*1024)
+	defer writer.Flush()
+
+	var e replyEncoder
+	switch format {
+	case "pickle":
+		e = newPickleEncoder(writer, from, until)
+	case "protobuf", "carbonapi_v2_pb":
+		e = newProtobufEncoder(writer, from, until)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		e = newCSVEncoder(writer, from, until)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		e = newJSONEncoder(writer, from, until)
+	}
+
+	e.start()
+	for _, s := range list {
+		if s.step == 0 {
+			continue
+		}
+		e.writeMetric(s.name, s.pathExpression, s.points(), s.step)
+	}
+	e.finish()
+}
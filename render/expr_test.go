@@ -0,0 +1,166 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestIsExprTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsExprTarget("sumSeries(a.*.cpu)"))
+	assert.True(IsExprTarget("alias(seriesByTag('name=cpu'), 'cpu')"))
+	assert.False(IsExprTarget("a.*.cpu"))
+	assert.False(IsExprTarget("a.{b,c}.cpu"))
+	assert.False(IsExprTarget("seriesByTag('name=cpu')"))
+}
+
+func TestRenderExpr(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 179
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\na.b.d\n")
+		}
+		return makeData([]testPoint{
+			{"a.b.c", 1, from - 120, 10},
+			{"a.b.c", 2, from - 60, 10},
+			{"a.b.c", 3, from, 10},
+			{"a.b.c", 4, from + 60, 10},
+			{"a.b.d", 10, from, 10},
+			{"a.b.d", 20, from + 120, 10},
+		})
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.Rollup = r
+
+	render := func(target string, format string) string {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=%s&from=%d&until=%d&format=%s", target, from, until, format), nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+
+		assert.Equal(200, w.Code, target)
+		return w.Body.String()
+	}
+
+	assert.Equal(
+		`[{"target":"sumSeries(a.b.*)","tags":{"name":"sumSeries(a.b.*)"},"datapoints":[[13,1520056800],[4,1520056860],[20,1520056920]]}]`,
+		render("sumSeries(a.b.*)", "json"),
+	)
+
+	assert.Equal(
+		`[{"target":"c","tags":{"name":"c"},"datapoints":[[2,1520056800],[3,1520056860],[3.5,1520056920]]},`+
+			`{"target":"d","tags":{"name":"d"},"datapoints":[[10,1520056800],[10,1520056860],[15,1520056920]]}]`,
+		render("aliasByNode(movingAverage(a.b.*,'3min'),2)", "json"),
+	)
+
+	// fetch is extended for movingAverage window
+	requests := srv.Requests()
+	assert.Contains(string(requests[len(requests)-1].Query), fmt.Sprintf("Time >= %d", from-180))
+
+	assert.Equal(
+		"sumSeries(a.b.*),2018-03-03 06:00:00,13\nsumSeries(a.b.*),2018-03-03 06:01:00,4\nsumSeries(a.b.*),2018-03-03 06:02:00,20\n",
+		render("sumSeries(a.b.*)", "csv"),
+	)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=unknown(a.b.c)&from=%d&until=%d&format=json", from, until), nil)
+	w := httptest.NewRecorder()
+	NewHandler(cfg).ServeHTTP(w, req)
+	assert.Equal(400, w.Code)
+	assert.Contains(w.Body.String(), "unknown function")
+}
+
+func TestRenderExprPlainTargets(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<pattern>
+		<regexp>^a\.b\.c$</regexp>
+		<function>sum</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</pattern>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 179
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\n")
+		}
+		return makeData([]testPoint{
+			{"a.b.c", 1, from, 10},
+			{"a.b.c", 2, from + 60, 10},
+			{"a.b.c", 3, from + 120, 10},
+		})
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.Rollup = r
+
+	render := func(query string) (int, string) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?%s&from=%d&until=%d&format=json", query, from, until), nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	// plain target is consolidated with sum of rollup, derived series with avg
+	code, body := render("target=a.b.c&target=alias(a.b.c,'x')&maxDataPoints=1")
+	assert.Equal(200, code, body)
+	assert.Equal(
+		`[{"target":"a.b.c","tags":{"name":"a.b.c"},"datapoints":[[6,1520056800]]},`+
+			`{"target":"x","tags":{"name":"x"},"datapoints":[[2,1520056800]]}]`,
+		body,
+	)
+
+	// plain target rejected by parser is resolved by finder
+	code, body = render("target=" + url.QueryEscape("-a.b") + "&target=alias(a.b.c,'x')")
+	assert.Equal(200, code, body)
+	assert.Contains(body, `"target":"x"`)
+}
@@ -0,0 +1,80 @@
+package render
+
+import (
+	"context"
+	"io"
+
+	"github.com/lomik/graphite-clickhouse/helper/point"
+)
+
+// minChunkSize is minimal count of metrics in one chunked data query
+const minChunkSize = 100
+
+// chunkPaths splits paths to at most n chunks of nearly equal size
+func chunkPaths(paths []string, n int) [][]string {
+	if max := len(paths) / minChunkSize; n > max {
+		n = max
+	}
+	if n <= 1 {
+		return [][]string{paths}
+	}
+
+	chunks := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		chunks = append(chunks, paths[i*len(paths)/n:(i+1)*len(paths)/n])
+	}
+	return chunks
+}
+
+type parseResult struct {
+	data *Data
+	err  error
+}
+
+// fetchConcurrent executes data requests concurrently and merges parsed points with extraPoints
+// in order of requests as soon as each of them is parsed. Remaining requests are canceled on first error
+func fetchConcurrent(ctx context.Context, requests []dataRequest, open func(ctx context.Context, req dataRequest) (io.ReadCloser, error), extraPoints func() *point.Points, isReverse bool) (*Data, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan parseResult, len(requests))
+	for i, req := range requests {
+		results[i] = make(chan parseResult, 1)
+
+		go func(req dataRequest, result chan parseResult) {
+			body, err := open(ctx, req)
+			if err != nil {
+				result <- parseResult{err: err}
+				return
+			}
+			defer body.Close()
+
+			data, err := DataParse(body, nil, isReverse)
+			result <- parseResult{data: data, err: err}
+		}(req, results[i])
+	}
+
+	var data *Data
+	for _, result := range results {
+		r := <-result
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		if data == nil {
+			data = r.data
+		} else {
+			data.Points.Merge(r.data.Points)
+		}
+	}
+
+	if data == nil {
+		data = &Data{Points: point.NewPoints()}
+	}
+
+	if extra := extraPoints(); extra != nil {
+		data.Points.Merge(extra)
+	}
+
+	return data, nil
+}
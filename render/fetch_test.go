@@ -0,0 +1,167 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestChunkPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	paths := make([]string, 350)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("m.%d", i)
+	}
+
+	table := []struct {
+		n     int
+		sizes []int
+	}{
+		{0, []int{350}},
+		{1, []int{350}},
+		{2, []int{175, 175}},
+		{3, []int{116, 117, 117}},
+		// at least minChunkSize metrics in chunk
+		{10, []int{116, 117, 117}},
+	}
+
+	for _, test := range table {
+		chunks := chunkPaths(paths, test.n)
+
+		sizes := make([]int, len(chunks))
+		var joined []string
+		for i, c := range chunks {
+			sizes[i] = len(c)
+			joined = append(joined, c...)
+		}
+		assert.Equal(test.sizes, sizes, "n = %d", test.n)
+		assert.Equal(paths, joined, "n = %d", test.n)
+	}
+}
+
+func TestFetchConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	bodies := map[string][]byte{
+		"a": makeData([]testPoint{{"a.b", 1, 10, 10}}),
+		"b": makeData([]testPoint{{"c.d", 2, 10, 10}, {"a.b", 3, 20, 10}}),
+	}
+
+	open := func(ctx context.Context, req dataRequest) (io.ReadCloser, error) {
+		body, ok := bodies[req.query]
+		if !ok {
+			return nil, errors.New("unknown query")
+		}
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	extra := func() *point.Points {
+		pp := point.NewPoints()
+		pp.AppendPoint(pp.MetricID("e.f"), 4, 10, 10)
+		return pp
+	}
+
+	data, err := fetchConcurrent(context.Background(), []dataRequest{{query: "a"}, {query: "b"}}, open, extra, false)
+	assert.NoError(err)
+	if assert.NotNil(data) {
+		// merged in order of requests, extra points are the last
+		assert.Equal([]point.Point{
+			{MetricID: 1, Value: 1, Time: 10, Timestamp: 10},
+			{MetricID: 2, Value: 2, Time: 10, Timestamp: 10},
+			{MetricID: 1, Value: 3, Time: 20, Timestamp: 10},
+			{MetricID: 3, Value: 4, Time: 10, Timestamp: 10},
+		}, data.Points.List())
+		assert.Equal("c.d", data.Points.MetricName(2))
+	}
+
+	_, err = fetchConcurrent(context.Background(), []dataRequest{{query: "a"}, {query: "fail"}}, open, extra, false)
+	assert.Error(err)
+}
+
+// BenchmarkRenderChunks compares latency of render with one data query and with chunked queries.
+// Test server spends fixed time on each metric like single clickhouse thread reading data
+func BenchmarkRenderChunks(b *testing.B) {
+	const metricsCount = 2000
+	const metricDelay = 10 * time.Microsecond
+
+	var from uint32 = 1520056800
+
+	tree := new(bytes.Buffer)
+	for i := 0; i < metricsCount; i++ {
+		fmt.Fprintf(tree, "m.%d\n", i)
+	}
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	pathRe := regexp.MustCompile(`'(m\.[0-9]+)'`)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		query := string(body)
+
+		if strings.Contains(query, "graphite_tree") {
+			w.Write(tree.Bytes())
+			return
+		}
+
+		var points []testPoint
+		for _, m := range pathRe.FindAllStringSubmatch(query, -1) {
+			for t := uint32(0); t < 10; t++ {
+				points = append(points, testPoint{m[1], float64(t), from + t*60, from})
+			}
+		}
+		time.Sleep(time.Duration(len(points)/10) * metricDelay)
+		w.Write(makeData(points))
+	}))
+	defer srv.Close()
+
+	for _, chunks := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("chunks=%d", chunks), func(b *testing.B) {
+			cfg := config.New()
+			cfg.ClickHouse.Url = srv.URL
+			cfg.Rollup = r
+			cfg.ClickHouse.ExternalDataThreshold = 0
+			cfg.ClickHouse.DataChunks = chunks
+			h := NewHandler(cfg)
+
+			url := fmt.Sprintf("/render/?target=m.*&from=%d&until=%d&format=protobuf", from, from+599)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+				if w.Code != http.StatusOK {
+					b.Fatal(w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-graphite/carbonapi/pkg/parser"
+)
+
+func init() {
+	exprFunctions = map[string]exprFunc{
+		"sumSeries":      aggregateSeries(aggregateSum),
+		"sum":            aggregateSeries(aggregateSum),
+		"averageSeries":  aggregateSeries(aggregateAvg),
+		"avg":            aggregateSeries(aggregateAvg),
+		"minSeries":      aggregateSeries(aggregateMin),
+		"maxSeries":      aggregateSeries(aggregateMax),
+		"countSeries":    aggregateSeries(aggregateCount),
+		"multiplySeries": aggregateSeries(aggregateMultiply),
+		"aggregate":      aggregateWithFunc,
+		"scale":          scale,
+		"offset":         offset,
+		"alias":          alias,
+		"aliasByNode":    aliasByNode,
+		"aliasByMetric":  aliasByMetric,
+		"aliasByTags":    aliasByTags,
+		"aliasSub":       aliasSub,
+		"groupByNode":    groupByNode,
+		"groupByNodes":   groupByNodes,
+		"groupByTags":    groupByTags,
+		"movingAverage":  movingAverage,
+		"perSecond":      perSecond,
+		"summarize":      summarize,
+	}
+}
+
+// evalFirst evaluates first argument of function as series list
+func (ctx *evalContext) evalFirst(e parser.Expr) ([]*series, error) {
+	args := e.Args()
+	if len(args) == 0 {
+		return nil, parser.ErrMissingTimeseries
+	}
+	return ctx.eval(args[0])
+}
+
+// formatPathExpressions returns unique path expressions of series joined with comma like graphite-web
+func formatPathExpressions(list []*series) string {
+	uniq := make(map[string]bool)
+	a := make([]string, 0, len(list))
+	for _, s := range list {
+		if !uniq[s.pathExpression] {
+			uniq[s.pathExpression] = true
+			a = append(a, s.pathExpression)
+		}
+	}
+	return strings.Join(a, ",")
+}
+
+// metricName returns metric of series name without functions and tags: "scale(a.b.c,2)" -> "a.b.c"
+func metricName(name string) string {
+	if i := strings.LastIndexByte(name, '('); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexAny(name, ",)"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// nodes returns nodes of metric name by indexes. Negative index is counted from the end
+func nodes(name string, indexes []int) string {
+	parts := strings.Split(metricName(name), ".")
+	result := make([]string, 0, len(indexes))
+	for _, n := range indexes {
+		if n < 0 {
+			n += len(parts)
+		}
+		if n >= 0 && n < len(parts) {
+			result = append(result, parts[n])
+		}
+	}
+	return strings.Join(result, ".")
+}
+
+// mapValues returns copy of series with new name and values calculated by f
+func mapValues(s *series, name string, f func(v float64) float64) *series {
+	r := s.copy()
+	r.name = name
+	r.pathExpression = name
+	for i, v := range r.values {
+		if !math.IsNaN(v) {
+			r.values[i] = f(v)
+		}
+	}
+	return r
+}
+
+// groupSeries aggregates series with the same key. Groups are returned in order of first series
+func groupSeries(list []*series, key func(s *series) string, aggr aggregateFunc) []*series {
+	keys := make([]string, 0)
+	groups := make(map[string][]*series)
+	for _, s := range list {
+		k := key(s)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+
+	result := make([]*series, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, combine(k, groups[k], aggr))
+	}
+	return result
+}
+
+// sumSeries(*seriesLists)
+func aggregateSeries(aggr aggregateFunc) exprFunc {
+	return func(ctx *evalContext, e parser.Expr) ([]*series, error) {
+		list, err := ctx.evalArgs(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, nil
+		}
+		name := fmt.Sprintf("%s(%s)", e.Target(), formatPathExpressions(list))
+		return []*series{combine(name, list, aggr)}, nil
+	}
+}
+
+// aggregate(seriesList, func)
+func aggregateWithFunc(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := e.GetStringNamedOrPosArgDefault("func", 1, "")
+	if err != nil {
+		return nil, err
+	}
+	aggr, err := getAggregateFunc(fn)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	name := fmt.Sprintf("%sSeries(%s)", strings.TrimSuffix(fn, "Series"), formatPathExpressions(list))
+	return []*series{combine(name, list, aggr)}, nil
+}
+
+// scale(seriesList, factor)
+func scale(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := e.GetFloatArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		result[i] = mapValues(s, fmt.Sprintf("scale(%s,%g)", s.name, factor), func(v float64) float64 { return v * factor })
+	}
+	return result, nil
+}
+
+// offset(seriesList, factor)
+func offset(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := e.GetFloatArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		result[i] = mapValues(s, fmt.Sprintf("offset(%s,%g)", s.name, factor), func(v float64) float64 { return v + factor })
+	}
+	return result, nil
+}
+
+// alias(seriesList, newName)
+func alias(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	name, err := e.GetStringArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		result[i] = s.rename(name)
+	}
+	return result, nil
+}
+
+// aliasByNode(seriesList, *nodes)
+func aliasByNode(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := e.GetIntArgs(1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		result[i] = s.rename(nodes(s.name, indexes))
+	}
+	return result, nil
+}
+
+// aliasByMetric(seriesList)
+func aliasByMetric(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		result[i] = s.rename(nodes(s.name, []int{-1}))
+	}
+	return result, nil
+}
+
+// aliasByTags(seriesList, *nodesOrTags)
+func aliasByTags(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	args := e.Args()[1:]
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		tags := graphiteTags(metricName(s.name) + tagsOf(s.name))
+		a := make([]string, 0, len(args))
+		for _, arg := range args {
+			switch {
+			case arg.IsConst():
+				a = append(a, nodes(s.name, []int{int(arg.FloatValue())}))
+			case arg.IsString():
+				a = append(a, tags[arg.StringValue()])
+			default:
+				return nil, parser.ErrBadType
+			}
+		}
+		result[i] = s.rename(strings.Join(a, "."))
+	}
+	return result, nil
+}
+
+// tagsOf returns ";k=v" part of series name
+func tagsOf(name string) string {
+	i := strings.IndexByte(name, ';')
+	if i < 0 {
+		return ""
+	}
+	tags := name[i:]
+	if j := strings.IndexAny(tags, ",)"); j >= 0 {
+		tags = tags[:j]
+	}
+	return tags
+}
+
+// aliasSub(seriesList, search, replace)
+func aliasSub(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	search, err := e.GetStringArg(1)
+	if err != nil {
+		return nil, err
+	}
+	replace, err := e.GetStringArg(2)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(search)
+	if err != nil {
+		return nil, err
+	}
+	// python style backreferences \1 to go style ${1}
+	replace = regexp.MustCompile(`\\(\d+)`).ReplaceAllString(replace, "$${$1}")
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		result[i] = s.rename(re.ReplaceAllString(s.name, replace))
+	}
+	return result, nil
+}
+
+// groupByNode(seriesList, nodeNum, callback="average")
+func groupByNode(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	node, err := e.GetIntArg(1)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := e.GetStringNamedOrPosArgDefault("callback", 2, "average")
+	if err != nil {
+		return nil, err
+	}
+	aggr, err := getAggregateFunc(callback)
+	if err != nil {
+		return nil, err
+	}
+
+	return groupSeries(list, func(s *series) string { return nodes(s.name, []int{node}) }, aggr), nil
+}
+
+// groupByNodes(seriesList, callback, *nodes)
+func groupByNodes(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := e.GetStringArg(1)
+	if err != nil {
+		return nil, err
+	}
+	aggr, err := getAggregateFunc(callback)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []int
+	if len(e.Args()) > 2 {
+		indexes, err = e.GetIntArgs(2)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return groupSeries(list, func(s *series) string { return nodes(s.name, indexes) }, aggr), nil
+}
+
+// groupByTags(seriesList, callback, *tags)
+func groupByTags(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := e.GetStringArg(1)
+	if err != nil {
+		return nil, err
+	}
+	aggr, err := getAggregateFunc(callback)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupTags []string
+	for i := 2; i < len(e.Args()); i++ {
+		tag, err := e.GetStringArg(i)
+		if err != nil {
+			return nil, err
+		}
+		groupTags = append(groupTags, tag)
+	}
+	if len(groupTags) == 0 {
+		return nil, parser.ErrMissingArgument
+	}
+	sort.Strings(groupTags)
+
+	key := func(s *series) string {
+		tags := graphiteTags(metricName(s.name) + tagsOf(s.name))
+
+		name := strings.TrimSuffix(callback, "Series") + "Series"
+		var b strings.Builder
+		for _, tag := range groupTags {
+			if tag == "name" {
+				name = tags["name"]
+				continue
+			}
+			b.WriteString(";" + tag + "=" + tags[tag])
+		}
+		return name + b.String()
+	}
+
+	return groupSeries(list, key, aggr), nil
+}
+
+// movingAverage(seriesList, windowSize). windowSize is number of points or interval string
+func movingAverage(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Args()) < 2 {
+		return nil, parser.ErrMissingArgument
+	}
+
+	var window int
+	var windowSeconds int32
+	var windowName string
+	if e.Args()[1].IsString() {
+		windowSeconds, err = e.GetIntervalArg(1, 1)
+		if err != nil {
+			return nil, err
+		}
+		windowName = strconv.Quote(e.Args()[1].StringValue())
+	} else {
+		window, err = e.GetIntArg(1)
+		if err != nil {
+			return nil, err
+		}
+		windowName = strconv.Itoa(window)
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		n := window
+		if windowSeconds > 0 {
+			n = int(uint32(windowSeconds) / s.step)
+		}
+		if n < 1 {
+			n = 1
+		}
+
+		r := s.copy()
+		r.name = fmt.Sprintf("movingAverage(%s,%s)", s.name, windowName)
+		r.pathExpression = r.name
+		for j := range r.values {
+			k := j - n + 1
+			if k < 0 {
+				k = 0
+			}
+			r.values[j] = aggregateAvg(s.values[k : j+1])
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+// perSecond(seriesList, maxValue=None)
+func perSecond(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	maxValue, err := e.GetFloatNamedOrPosArgDefault("maxValue", 1, math.NaN())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		r := s.copy()
+		r.name = fmt.Sprintf("perSecond(%s)", s.name)
+		r.pathExpression = r.name
+
+		prev := -1 // index of previous present value
+		for j, v := range s.values {
+			r.values[j] = math.NaN()
+			if math.IsNaN(v) {
+				continue
+			}
+			if prev >= 0 {
+				diff := v - s.values[prev]
+				seconds := float64(uint32(j-prev) * s.step)
+				if diff >= 0 {
+					r.values[j] = diff / seconds
+				} else if !math.IsNaN(maxValue) && maxValue >= v {
+					// counter wrapped
+					r.values[j] = (maxValue - s.values[prev] + v + 1) / seconds
+				}
+			}
+			prev = j
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+// summarize(seriesList, intervalString, func="sum", alignToFrom=False). Buckets are aligned to interval
+func summarize(ctx *evalContext, e parser.Expr) ([]*series, error) {
+	list, err := ctx.evalFirst(e)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := e.GetIntervalArg(1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("summarize: interval must be positive")
+	}
+	intervalString, _ := e.GetStringArg(1)
+	fn, err := e.GetStringNamedOrPosArgDefault("func", 2, "sum")
+	if err != nil {
+		return nil, err
+	}
+	aggr, err := getAggregateFunc(fn)
+	if err != nil {
+		return nil, err
+	}
+	alignToFrom, err := e.GetBoolNamedOrPosArgDefault("alignToFrom", 3, false)
+	if err != nil {
+		return nil, err
+	}
+	if alignToFrom {
+		return nil, fmt.Errorf("summarize: alignToFrom is not supported")
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		r := s.consolidate(uint32(interval), aggr)
+		r.name = fmt.Sprintf("summarize(%s, \"%s\", \"%s\")", s.name, intervalString, fn)
+		r.pathExpression = r.name
+		result[i] = r
+	}
+	return result, nil
+}
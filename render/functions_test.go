@@ -0,0 +1,118 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-graphite/carbonapi/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLeaves() map[string][]*series {
+	nan := math.NaN()
+	return map[string][]*series{
+		"a.*.cpu": {
+			{name: "a.b.cpu", pathExpression: "a.*.cpu", start: 60, step: 60, values: []float64{1, 2, 3, 4}},
+			{name: "a.c.cpu", pathExpression: "a.*.cpu", start: 60, step: 60, values: []float64{10, nan, 30, 40}},
+		},
+		"counter": {
+			{name: "counter", pathExpression: "counter", start: 60, step: 60, values: []float64{60, 120, nan, 240, 60}},
+		},
+		"seriesByTag('name=cpu')": {
+			{name: "cpu;dc=1;host=a", pathExpression: "seriesByTag('name=cpu')", start: 60, step: 60, values: []float64{1, 2}},
+			{name: "cpu;dc=1;host=b", pathExpression: "seriesByTag('name=cpu')", start: 60, step: 60, values: []float64{3, 4}},
+			{name: "cpu;dc=2;host=c", pathExpression: "seriesByTag('name=cpu')", start: 60, step: 60, values: []float64{5, 6}},
+		},
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	nan := math.NaN()
+
+	table := []struct {
+		target string
+		names  []string
+		values [][]float64
+	}{
+		{"sumSeries(a.*.cpu)", []string{"sumSeries(a.*.cpu)"}, [][]float64{{11, 2, 33, 44}}},
+		{"averageSeries(a.*.cpu, counter)", []string{"averageSeries(a.*.cpu,counter)"}, [][]float64{{23.666666666666668, 61, 16.5, 94.66666666666667, 60}}},
+		{"aggregate(a.*.cpu, 'max')", []string{"maxSeries(a.*.cpu)"}, [][]float64{{10, 2, 30, 40}}},
+		{"scale(a.*.cpu, 2)", []string{"scale(a.b.cpu,2)", "scale(a.c.cpu,2)"}, [][]float64{{2, 4, 6, 8}, {20, nan, 60, 80}}},
+		{"offset(counter, -60)", []string{"offset(counter,-60)"}, [][]float64{{0, 60, nan, 180, 0}}},
+		{"alias(sumSeries(a.*.cpu), 'total')", []string{"total"}, [][]float64{{11, 2, 33, 44}}},
+		{"aliasByNode(a.*.cpu, 1)", []string{"b", "c"}, nil},
+		{"aliasByNode(scale(a.*.cpu, 2), 0, -1)", []string{"a.cpu", "a.cpu"}, nil},
+		{"aliasByMetric(a.*.cpu)", []string{"cpu", "cpu"}, nil},
+		{"aliasSub(a.*.cpu, '^a\\.(\\w+)\\.cpu$', 'host \\1')", []string{"host b", "host c"}, nil},
+		{"aliasByTags(seriesByTag('name=cpu'), 'host', 'name')", []string{"a.cpu", "b.cpu", "c.cpu"}, nil},
+		{"groupByNode(a.*.cpu, 2, 'sum')", []string{"cpu"}, [][]float64{{11, 2, 33, 44}}},
+		{"groupByNodes(a.*.cpu, 'max', 0, 2)", []string{"a.cpu"}, [][]float64{{10, 2, 30, 40}}},
+		{"groupByTags(seriesByTag('name=cpu'), 'sum', 'dc')", []string{"sumSeries;dc=1", "sumSeries;dc=2"}, [][]float64{{4, 6}, {5, 6}}},
+		{"groupByTags(seriesByTag('name=cpu'), 'max', 'name', 'dc')", []string{"cpu;dc=1", "cpu;dc=2"}, [][]float64{{3, 4}, {5, 6}}},
+		{"movingAverage(a.*.cpu, 2)", []string{"movingAverage(a.b.cpu,2)", "movingAverage(a.c.cpu,2)"}, [][]float64{{1, 1.5, 2.5, 3.5}, {10, 10, 30, 35}}},
+		{"movingAverage(counter, '3min')", []string{`movingAverage(counter,"3min")`}, [][]float64{{60, 90, 90, 180, 150}}},
+		{"perSecond(counter)", []string{"perSecond(counter)"}, [][]float64{{nan, 1, nan, 1, nan}}},
+		{"perSecond(counter, 299)", []string{"perSecond(counter)"}, [][]float64{{nan, 1, nan, 1, 2}}},
+		{"summarize(a.*.cpu, '2min')", []string{`summarize(a.b.cpu, "2min", "sum")`, `summarize(a.c.cpu, "2min", "sum")`}, [][]float64{{1, 5, 4}, {10, 30, 40}}},
+		{"summarize(a.*.cpu, '2min', 'max')", []string{`summarize(a.b.cpu, "2min", "max")`, `summarize(a.c.cpu, "2min", "max")`}, [][]float64{{1, 3, 4}, {10, 30, 40}}},
+	}
+
+	for _, test := range table {
+		e, _, err := parser.Parse(test.target)
+		if !assert.NoError(t, err, test.target) {
+			continue
+		}
+
+		ctx := &evalContext{leaves: testLeaves()}
+		result, err := ctx.eval(e)
+		if !assert.NoError(t, err, test.target) {
+			continue
+		}
+
+		names := make([]string, len(result))
+		values := make([][]float64, len(result))
+		for i, s := range result {
+			names[i] = s.name
+			values[i] = s.values
+		}
+		assert.Equal(t, test.names, names, test.target)
+
+		if test.values == nil {
+			continue
+		}
+		if assert.Equal(t, len(test.values), len(values), test.target) {
+			for i := range values {
+				assert.Equal(t, len(test.values[i]), len(values[i]), test.target)
+				for j := 0; j < len(values[i]) && j < len(test.values[i]); j++ {
+					if math.IsNaN(test.values[i][j]) {
+						assert.True(t, math.IsNaN(values[i][j]), "%s: values[%d][%d] = %v", test.target, i, j, values[i][j])
+					} else {
+						assert.Equal(t, test.values[i][j], values[i][j], "%s: values[%d][%d]", test.target, i, j)
+					}
+				}
+			}
+		}
+	}
+
+	// sources are not modified
+	assert.Equal(t, testLeaves()["a.*.cpu"][0].values, []float64{1, 2, 3, 4})
+}
+
+func TestFunctionErrors(t *testing.T) {
+	for _, target := range []string{
+		"unknownFunction(a.*.cpu)",
+		"scale(a.*.cpu)",
+		"groupByNode(a.*.cpu, 1, 'unknown')",
+		"summarize(a.*.cpu, '1h', 'sum', true)",
+		"sumSeries()",
+	} {
+		e, _, err := parser.Parse(target)
+		if !assert.NoError(t, err, target) {
+			continue
+		}
+
+		ctx := &evalContext{leaves: testLeaves()}
+		_, err = ctx.eval(e)
+		assert.Error(t, err, target)
+	}
+}
@@ -1,11 +1,13 @@
 package render
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/lomik/graphite-clickhouse/helper/rollup"
@@ -14,35 +16,86 @@ import (
 
 	"github.com/lomik/graphite-clickhouse/config"
 	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/cache"
 	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
 	"github.com/lomik/graphite-clickhouse/helper/log"
+	"github.com/lomik/graphite-clickhouse/helper/metrics"
 	"github.com/lomik/graphite-clickhouse/helper/point"
 
 	graphitePickle "github.com/lomik/graphite-pickle"
 )
 
+// dataGroup is set of metrics consolidated in one data query
+type dataGroup struct {
+	pattern  *rollup.Pattern // rollup rules applied in clickhouse, nil - rollup in reply only
+	step     uint32          // consolidation step, 0 - without consolidation
+	function string          // rollup function
+}
+
+// precisions returns steps of aggregation in clickhouse one after another
+func (g dataGroup) precisions(from uint32) []uint32 {
+	var precisions []uint32
+	if g.pattern != nil {
+		precisions = g.pattern.Precisions(from)
+	}
+	if g.step > 0 {
+		precisions = append(precisions, g.step)
+	}
+	return precisions
+}
+
 type Handler struct {
 	config     *config.Config
 	carbonlink *graphitePickle.CarbonlinkClient
+	group      cache.Group // coalesces identical data requests
 }
 
 func NewHandler(config *config.Config) *Handler {
-	h := &Handler{
-		config: config,
+	return &Handler{
+		config:     config,
+		carbonlink: carbonlinkClient(config.Carbonlink),
 	}
+}
+
+// carbonlink client of the last created handler
+var carbonlinkShared struct {
+	sync.Mutex
+	config config.Carbonlink
+	client *graphitePickle.CarbonlinkClient
+}
+
+// carbonlinkClient returns nil if carbonlink is disabled. Handlers recreated on config reload share client
+// with its pool of connections while carbonlink config is not changed
+func carbonlinkClient(cfg config.Carbonlink) *graphitePickle.CarbonlinkClient {
+	if cfg.Server == "" {
+		return nil
+	}
+
+	carbonlinkShared.Lock()
+	defer carbonlinkShared.Unlock()
 
-	if config.Carbonlink.Server != "" {
-		h.carbonlink = graphitePickle.NewCarbonlinkClient(
-			config.Carbonlink.Server,
-			config.Carbonlink.Retries,
-			config.Carbonlink.Threads,
-			config.Carbonlink.ConnectTimeout.Value(),
-			config.Carbonlink.QueryTimeout.Value(),
-		)
+	if carbonlinkShared.client != nil && reflect.DeepEqual(carbonlinkShared.config, cfg) {
+		return carbonlinkShared.client
 	}
-	return h
+
+	carbonlinkShared.config = cfg
+	carbonlinkShared.client = graphitePickle.NewCarbonlinkClient(
+		cfg.Server,
+		cfg.Retries,
+		cfg.Threads,
+		cfg.ConnectTimeout.Value(),
+		cfg.QueryTimeout.Value(),
+	)
+	return carbonlinkShared.client
 }
 
+var (
+	carbonlinkRequests = metrics.NewCounter("carbonlink_requests_total")
+	carbonlinkErrors   = metrics.NewCounter("carbonlink_errors_total")
+	carbonlinkMetrics  = metrics.NewCounter("carbonlink_metrics_total")
+	carbonlinkHits     = metrics.NewCounter("carbonlink_hits_total")
+)
+
 // returns callable result fetcher
 func (h *Handler) queryCarbonlink(parentCtx context.Context, logger *zap.Logger, merticsList [][]byte) func() *point.Points {
 	if h.carbonlink == nil {
@@ -67,7 +120,12 @@ func (h *Handler) queryCarbonlink(parentCtx context.Context, logger *zap.Logger,
 
 		res, err := h.carbonlink.CacheQueryMulti(ctx, metrics)
 
+		carbonlinkRequests.Inc()
+		carbonlinkMetrics.Add(uint64(len(metrics)))
+		carbonlinkHits.Add(uint64(len(res)))
+
 		if err != nil {
+			carbonlinkErrors.Inc()
 			logger.Info("carbonlink failed", zap.Error(err))
 		}
 
@@ -91,13 +149,21 @@ func (h *Handler) queryCarbonlink(parentCtx context.Context, logger *zap.Logger,
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	logger := log.FromContext(r.Context())
-
 	var prefix string
 	var err error
 
 	r.ParseMultipartForm(1024 * 1024)
 
+	if !IsKnownFormat(r.FormValue("format")) {
+		http.Error(w, fmt.Sprintf("Unknown format %#v", r.FormValue("format")), http.StatusBadRequest)
+		return
+	}
+
+	if r.FormValue("format") == "carbonapi_v3_pb" {
+		h.serveCarbonapiV3(w, r)
+		return
+	}
+
 	fromTimestamp, err := strconv.ParseInt(r.FormValue("from"), 10, 32)
 	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
@@ -110,36 +176,57 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	aliases := make(map[string][]string)
-	targets := make([]string, 0)
+	var maxDataPoints int
+	if r.FormValue("maxDataPoints") != "" {
+		maxDataPoints, err = strconv.Atoi(r.FormValue("maxDataPoints"))
+		if err != nil {
+			http.Error(w, "Bad request (malformed maxDataPoints)", http.StatusBadRequest)
+			return
+		}
+	}
 
-	for t := 0; t < len(r.Form["target"]); t++ {
-		target := r.Form["target"][t]
+	targets := make([]string, 0)
+	for _, target := range r.Form["target"] {
 		if len(target) == 0 {
 			continue
 		}
 		targets = append(targets, target)
+	}
 
-		// Search in small index table first
-		fndResult, err := finder.Find(h.config, r.Context(), target, fromTimestamp, untilTimestamp)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	for _, target := range targets {
+		if IsExprTarget(target) {
+			h.renderExpr(w, r, targets, fromTimestamp, untilTimestamp, maxDataPoints)
 			return
 		}
+	}
+
+	data, rollupObj, ok := h.render(w, r, targets, fromTimestamp, untilTimestamp, maxDataPoints, true)
+	if !ok {
+		return
+	}
 
-		fndSeries := fndResult.Series()
+	// pp.Println(points)
+	h.Reply(w, r, data, uint32(fromTimestamp), uint32(untilTimestamp), prefix, rollupObj)
+}
 
-		for i := 0; i < len(fndSeries); i++ {
-			key := string(fndSeries[i])
-			abs := string(fndResult.Abs(fndSeries[i]))
-			if x, ok := aliases[key]; ok {
-				aliases[key] = append(x, abs, target)
-			} else {
-				aliases[key] = []string{abs, target}
-			}
-		}
+// render finds series of targets and fetches their points. Returns false if reply is already written:
+// error or streamed reply. Reply is streamed only if allowStream is set
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, targets []string, fromTimestamp int64, untilTimestamp int64, maxDataPoints int, allowStream bool) (*Data, *rollup.Rollup, bool) {
+	// Search in small index table first
+	aliases, err := findTargets(r.Context(), h.config, targets, fromTimestamp, untilTimestamp, h.config.ClickHouse.FindConcurrency)
+	if err != nil {
+		http.Error(w, err.Error(), finder.HTTPStatus(err))
+		return nil, nil, false
 	}
 
+	return h.renderAliases(w, r, targets, aliases, fromTimestamp, untilTimestamp, maxDataPoints, allowStream)
+}
+
+// renderAliases fetches points of series already found for targets. aliases is result of findTargets
+func (h *Handler) renderAliases(w http.ResponseWriter, r *http.Request, targets []string, aliases map[string][]string, fromTimestamp int64, untilTimestamp int64, maxDataPoints int, allowStream bool) (*Data, *rollup.Rollup, bool) {
+	var err error
+	logger := log.FromContext(r.Context())
+
 	metricList := make([][]byte, len(aliases))
 	index := 0
 	for metric, _ := range aliases {
@@ -147,13 +234,37 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		index++
 	}
 
-	pointsTable, isReverse, rollupObj := SelectDataTable(h.config, fromTimestamp, untilTimestamp, targets)
+	if err := finder.CheckLimit("max-metrics-per-render", len(metricList), h.config.Limits.MaxMetricsPerRender); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	pointsTable, isReverse, rollupObj, rollupPushdown := SelectDataTable(h.config, fromTimestamp, untilTimestamp, targets)
+
+	// cached data is fetched for from and until aligned to the largest step, so dashboard requests which
+	// differ by seconds share it. Reply is cut to requested from and until
+	if h.config.RenderCache.Cache != nil {
+		var step uint32
+		for _, m := range metricList {
+			if s := rollupObj.Step(unsafeString(m), uint32(fromTimestamp)); s > step {
+				step = s
+			}
+		}
+		if step > 0 {
+			fromTimestamp -= fromTimestamp % int64(step)
+			untilTimestamp -= untilTimestamp % int64(step)
+		}
+	}
 
 	var maxStep uint32
-	listBuf := bytes.NewBuffer(nil)
+	var pointsCount int
+
+	// metrics grouped by consolidation step and rollup function
+	// zero dataGroup is for metrics without consolidation
+	groups := make(map[dataGroup][]string)
 
-	// make Path IN (...), calculate max step
-	for index, m := range metricList {
+	// make Path IN (...) for each group, calculate max step
+	for _, m := range metricList {
 		if len(m) == 0 {
 			continue
 		}
@@ -162,21 +273,40 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			maxStep = step
 		}
 
-		if index > 0 {
-			listBuf.WriteByte(',')
+		var g dataGroup
+		if rollupPushdown {
+			g.pattern = rollupObj.Match(unsafeString(m))
+			g.function = g.pattern.Function
+		}
+		if maxDataPoints > 0 {
+			consolidationStep := rollup.MaxDataPointsStep(step, uint32(fromTimestamp), uint32(untilTimestamp), maxDataPoints)
+			if consolidationStep > step {
+				g.step = consolidationStep
+				g.function = rollupObj.Match(unsafeString(m)).Function
+			}
+		}
+
+		if g.step > 0 {
+			pointsCount += int((untilTimestamp-fromTimestamp)/int64(g.step)) + 1
+		} else if step > 0 {
+			pointsCount += int((untilTimestamp-fromTimestamp)/int64(step)) + 1
 		}
 
 		if isReverse {
-			listBuf.WriteString("'" + clickhouse.Escape(reversePath(unsafeString(m))) + "'")
+			groups[g] = append(groups[g], reversePath(unsafeString(m)))
 		} else {
-			listBuf.WriteString("'" + clickhouse.Escape(unsafeString(m)) + "'")
+			groups[g] = append(groups[g], unsafeString(m))
 		}
 	}
 
-	if listBuf.Len() == 0 {
+	if len(groups) == 0 {
 		// Return empty response
-		h.Reply(w, r, EmptyData, 0, 0, "", nil)
-		return
+		return EmptyData, rollupObj, true
+	}
+
+	if err := finder.CheckLimit("max-points-per-render", pointsCount, h.config.Limits.MaxPointsPerRender); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
 	}
 
 	preWhere := finder.NewWhere()
@@ -186,68 +316,98 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		time.Unix(untilTimestamp, 0).Format("2006-01-02"),
 	)
 
-	where := finder.NewWhere()
-	where.Andf("Path in (%s)", listBuf.String())
-
-	until := untilTimestamp - untilTimestamp%int64(maxStep) + int64(maxStep) - 1
-	where.Andf("Time >= %d AND Time <= %d", fromTimestamp, until)
-
-	query := fmt.Sprintf(
-		`
-		SELECT
-			Path, Time, Value, Timestamp
-		FROM %s
-		PREWHERE (%s)
-		WHERE (%s)
-		FORMAT RowBinary
-		`,
-		pointsTable,
-		preWhere.String(),
-		where.String(),
-	)
+	// stream reply metric by metric from points sorted by clickhouse. Cached data requires all points in memory
+	streaming := allowStream && h.config.ClickHouse.RenderStreaming && h.config.RenderCache.Cache == nil && IsStreamableFormat(r.FormValue("format"))
 
-	// start carbonlink request
-	carbonlinkResponseRead := h.queryCarbonlink(r.Context(), logger, metricList)
+	requests := make([]dataRequest, 0, len(groups))
+	for g, groupPaths := range groups {
+		step := maxStep
+		if g.step > 0 {
+			step = g.step
+		}
 
-	body, err := clickhouse.Reader(
-		r.Context(),
-		h.config.ClickHouse.Url,
-		query,
-		pointsTable,
-		clickhouse.Options{Timeout: h.config.ClickHouse.DataTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()},
-	)
+		for _, paths := range chunkPaths(groupPaths, h.config.ClickHouse.DataChunks) {
+			pathIn, extData := PathIn(paths, h.config.ClickHouse.ExternalDataThreshold)
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+			where := finder.NewWhere()
+			where.And(pathIn)
+
+			until := untilTimestamp - untilTimestamp%int64(step) + int64(step) - 1
+			where.Andf("Time >= %d AND Time <= %d", fromTimestamp, until)
+
+			query := dataQuery(pointsTable, preWhere.String(), where.String(), g.precisions(uint32(fromTimestamp)), g.function, streaming)
+			if err := finder.CheckLimit("max-query-size", len(query), h.config.Limits.MaxQuerySize); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return nil, nil, false
+			}
+
+			requests = append(requests, dataRequest{query: query, extData: extData})
+		}
 	}
 
-	// fetch carbonlink response
-	carbonlinkData := carbonlinkResponseRead()
+	opts := clickhouse.Options{Timeout: h.config.ClickHouse.DataTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()}
+	open := func(ctx context.Context, req dataRequest) (io.ReadCloser, error) {
+		return req.reader(ctx, h.config.ClickHouse.Url, pointsTable, opts)
+	}
 
-	parseStart := time.Now()
+	if streaming {
+		carbonlinkData := h.queryCarbonlink(r.Context(), logger, metricList)()
+		h.replyStream(w, r, requests, open, carbonlinkData, aliases, isReverse, uint32(fromTimestamp), uint32(untilTimestamp), maxDataPoints, rollupObj)
+		return nil, nil, false
+	}
 
-	// pass carbonlinkData to DataParse
-	data, err := DataParse(body, carbonlinkData, isReverse)
+	// fetch points from clickhouse and carbonlink
+	fetch := func(ctx context.Context) (*Data, error) {
+		// start carbonlink request
+		carbonlinkResponseRead := h.queryCarbonlink(ctx, logger, metricList)
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fetchStart := time.Now()
+
+		// carbonlink response is merged after points from clickhouse
+		data, err := fetchConcurrent(ctx, requests, open, carbonlinkResponseRead, isReverse)
+		if err != nil {
+			return nil, err
+		}
+
+		d := time.Since(fetchStart)
+		logger.Debug("fetch", zap.String("runtime", d.String()), zap.Duration("runtime_ns", d), zap.Int("queries", len(requests)))
+
+		sortStart := time.Now()
+		data.Points.Sort()
+		d = time.Since(sortStart)
+		logger.Debug("sort", zap.String("runtime", d.String()), zap.Duration("runtime_ns", d))
+
+		data.Points.Uniq()
+
+		return data, nil
 	}
 
-	d := time.Since(parseStart)
-	logger.Debug("parse", zap.String("runtime", d.String()), zap.Duration("runtime_ns", d))
+	var data *Data
+	if h.config.RenderCache.Cache != nil {
+		key := renderCacheKey(targets, fromTimestamp, untilTimestamp, pointsTable, maxDataPoints)
+		data, err = h.cachedFetch(r.Context(), key, fetch)
+	} else {
+		data, err = fetch(r.Context())
+	}
 
-	sortStart := time.Now()
-	data.Points.Sort()
-	d = time.Since(sortStart)
-	logger.Debug("sort", zap.String("runtime", d.String()), zap.Duration("runtime_ns", d))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
 
-	data.Points.Uniq()
 	data.Aliases = aliases
+	data.MaxDataPoints = maxDataPoints
 
-	// pp.Println(points)
-	h.Reply(w, r, data, uint32(fromTimestamp), uint32(untilTimestamp), prefix, rollupObj)
+	return data, rollupObj, true
+}
+
+// IsKnownFormat returns true if format is supported by render handler. Empty format is json
+func IsKnownFormat(format string) bool {
+	switch format {
+	case "pickle", "protobuf", "carbonapi_v2_pb", "carbonapi_v3_pb", "json", "csv", "":
+		return true
+	}
+	return false
 }
 
 func (h *Handler) Reply(w http.ResponseWriter, r *http.Request, data *Data, from, until uint32, prefix string, rollupObj *rollup.Rollup) {
@@ -255,8 +415,14 @@ func (h *Handler) Reply(w http.ResponseWriter, r *http.Request, data *Data, from
 	switch r.FormValue("format") {
 	case "pickle":
 		h.ReplyPickle(w, r, data, from, until, prefix, rollupObj)
-	case "protobuf":
+	case "protobuf", "carbonapi_v2_pb":
 		h.ReplyProtobuf(w, r, data, from, until, prefix, rollupObj)
+	case "json", "":
+		h.ReplyJSON(w, r, data, from, until, prefix, rollupObj)
+	case "csv":
+		h.ReplyCSV(w, r, data, from, until, prefix, rollupObj)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown format %#v", r.FormValue("format")), http.StatusBadRequest)
 	}
 	d := time.Since(start)
 	log.FromContext(r.Context()).Debug("reply", zap.String("runtime", d.String()), zap.Duration("runtime_ns", d))
@@ -0,0 +1,168 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestRollupPushdown(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+		<retention>
+			<age>3600</age>
+			<precision>300</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 599
+
+	// raw points in points table
+	raw := makeData([]testPoint{
+		{"a.b.c", 1, from, 10},
+		{"a.b.c", 5, from, 20},
+		{"a.b.c", 3, from + 30, 10},
+		{"a.b.c", 4, from + 60, 10},
+		{"a.b.c", 10, from + 300, 10},
+		{"a.b.c", 20, from + 360, 10},
+		{"a.b.c", 30, from + 420, 10},
+	})
+
+	// same points deduplicated and rolled up to 60 and then to 300 seconds with avg
+	rolledUp := makeData([]testPoint{
+		{"a.b.c", 4, from, 20},
+		{"a.b.c", 20, from + 300, 10},
+	})
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		switch {
+		case bytes.Contains(query, []byte("graphite_tree")):
+			return []byte("a.b.c\n")
+		case bytes.Contains(query, []byte("argMax(Value, Timestamp)")):
+			return rolledUp
+		default:
+			return raw
+		}
+	})
+
+	render := func(pushdown bool) string {
+		cfg := config.New()
+		cfg.ClickHouse.Url = srv.URL
+		cfg.Rollup = r
+		if pushdown {
+			cfg.DataTable = []config.DataTable{{Table: "graphite", RollupPushdown: true}}
+		}
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=a.b.*&from=%d&until=%d&format=json", from, until), nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+
+		assert.Equal(200, w.Code)
+		return w.Body.String()
+	}
+
+	expected := render(false)
+	assert.Contains(expected, `"datapoints":[[4,1520056800],[20,1520057100]]`)
+	assert.Equal(expected, render(true))
+
+	requests := srv.Requests()
+	query := string(requests[len(requests)-1].Query)
+	assert.Contains(query, "intDiv(Time, 60) * 60")
+	assert.Contains(query, "intDiv(Time, 300) * 300")
+	assert.Contains(query, "avg(Value) AS V")
+}
+
+func TestRenderLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\na.b.d\n")
+		}
+		return nil
+	})
+
+	table := []struct {
+		limits   config.Limits
+		code     int
+		contains string
+	}{
+		{config.Limits{}, 200, ""},
+		{config.Limits{MaxSeriesPerTarget: 1}, 400, "max-series-per-target limit: 2 > 1"},
+		{config.Limits{MaxMetricsPerRender: 1}, 400, "max-metrics-per-render limit: 2 > 1"},
+		// 2 metrics * 11 points
+		{config.Limits{MaxPointsPerRender: 21}, 400, "max-points-per-render limit: 22 > 21"},
+		{config.Limits{MaxPointsPerRender: 22}, 200, ""},
+		{config.Limits{MaxQuerySize: 100}, 400, "max-query-size limit"},
+	}
+
+	for _, test := range table {
+		cfg := config.New()
+		cfg.ClickHouse.Url = srv.URL
+		cfg.Rollup = r
+		cfg.Limits = test.limits
+
+		req := httptest.NewRequest("GET", "/render/?target=a.b.*&from=1520056800&until=1520057400&format=json", nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+
+		assert.Equal(test.code, w.Code, "%#v", test.limits)
+		assert.Contains(w.Body.String(), test.contains, "%#v", test.limits)
+	}
+}
+
+func TestCarbonlinkClientReuse(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.New()
+	assert.Nil(NewHandler(cfg).carbonlink)
+
+	cfg.Carbonlink.Server = "127.0.0.1:7002"
+	h := NewHandler(cfg)
+	assert.NotNil(h.carbonlink)
+
+	// reloaded config with the same carbonlink section
+	reloaded := config.New()
+	reloaded.Carbonlink.Server = "127.0.0.1:7002"
+	reloaded.ClickHouse.DataTable = "graphite_reloaded"
+	assert.True(h.carbonlink == NewHandler(reloaded).carbonlink)
+
+	reloaded.Carbonlink.Server = "127.0.0.1:7003"
+	assert.False(h.carbonlink == NewHandler(reloaded).carbonlink)
+}
@@ -0,0 +1,291 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-graphite/carbonapi/pkg/parser"
+
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+// pushdownFunctions are clickhouse aggregate functions for graphite aggregations. Like aggregate
+// functions in reply they ignore absent values
+var pushdownFunctions = map[string]string{
+	"sum":     "sum",
+	"total":   "sum",
+	"avg":     "avg",
+	"average": "avg",
+	"min":     "min",
+	"max":     "max",
+	"count":   "count",
+}
+
+// aggregationPushdown is top-level cross-series aggregation of one leaf target evaluated by clickhouse
+type aggregationPushdown struct {
+	leaf     string   // aggregated target: metric glob or seriesByTag
+	function string   // clickhouse aggregate function
+	name     string   // name of result series. Empty for groupByTags
+	callback string   // groupByTags callback
+	tags     []string // sorted groupByTags tags
+}
+
+// parsePushdown returns aggregation of expression which can be pushed down to clickhouse or nil.
+// Supported are sumSeries, averageSeries, minSeries, maxSeries, countSeries and aggregate of one target
+// and groupByTags
+func parsePushdown(e parser.Expr) *aggregationPushdown {
+	if !e.IsFunc() || len(e.Args()) == 0 {
+		return nil
+	}
+
+	arg := e.Args()[0]
+	if !arg.IsName() && !(arg.IsFunc() && arg.Target() == "seriesByTag") {
+		return nil
+	}
+	leaf := leafTarget(arg)
+
+	var fn string
+	switch e.Target() {
+	case "sumSeries", "sum", "averageSeries", "avg", "minSeries", "maxSeries", "countSeries":
+		if len(e.Args()) != 1 {
+			return nil
+		}
+		fn = strings.TrimSuffix(e.Target(), "Series")
+		return pushdownOf(leaf, fn, fmt.Sprintf("%s(%s)", e.Target(), leaf))
+	case "aggregate":
+		var err error
+		fn, err = e.GetStringNamedOrPosArgDefault("func", 1, "")
+		if err != nil {
+			return nil
+		}
+		fn = strings.TrimSuffix(fn, "Series")
+		return pushdownOf(leaf, fn, fmt.Sprintf("%sSeries(%s)", fn, leaf))
+	case "groupByTags":
+		callback, err := e.GetStringArg(1)
+		if err != nil {
+			return nil
+		}
+		p := pushdownOf(leaf, strings.TrimSuffix(callback, "Series"), "")
+		if p == nil {
+			return nil
+		}
+		p.callback = callback
+		for i := 2; i < len(e.Args()); i++ {
+			tag, err := e.GetStringArg(i)
+			if err != nil {
+				return nil
+			}
+			p.tags = append(p.tags, tag)
+		}
+		if len(p.tags) == 0 {
+			return nil
+		}
+		sort.Strings(p.tags)
+		return p
+	}
+
+	return nil
+}
+
+func pushdownOf(leaf string, fn string, name string) *aggregationPushdown {
+	f, ok := pushdownFunctions[fn]
+	if !ok {
+		return nil
+	}
+	return &aggregationPushdown{leaf: leaf, function: f, name: name}
+}
+
+// keySQL returns clickhouse expression for name of result series. Name is built the same way as in groupByTags
+func (p *aggregationPushdown) keySQL() string {
+	if len(p.tags) == 0 {
+		return "'" + clickhouse.Escape(p.name) + "'"
+	}
+
+	name := "'" + clickhouse.Escape(strings.TrimSuffix(p.callback, "Series")+"Series") + "'"
+	parts := make([]string, 0, len(p.tags)*2+1)
+	for _, tag := range p.tags {
+		if tag == "name" {
+			name = "decodeURLComponent(splitByChar('?', Path)[1])"
+			continue
+		}
+		parts = append(parts,
+			"'"+clickhouse.Escape(";"+tag+"=")+"'",
+			"decodeURLComponent(extractURLParameter(Path, '"+clickhouse.Escape(tag)+"'))",
+		)
+	}
+	if len(parts) == 0 {
+		return name
+	}
+	return "concat(" + name + ", " + strings.Join(parts, ", ") + ")"
+}
+
+// query returns query of aggregated series. Points of each series are rolled up with rollup function
+// of pattern and then aggregated by time across series. Result columns are Path (name of result series),
+// Time, Value, Timestamp in RowBinary. Value is converted to Float64 because count returns UInt64
+func (p *aggregationPushdown) query(table string, preWhere string, where string, precisions []uint32, rollupFunction string) string {
+	return fmt.Sprintf(
+		`
+		SELECT
+			K, Time, toFloat64(%s(Value)), max(Timestamp)
+		FROM (
+			SELECT
+				%s AS K, Time, Value, Timestamp
+			FROM (%s)
+		)
+		GROUP BY K, Time
+		ORDER BY K, Time
+		FORMAT RowBinary
+		`,
+		p.function,
+		p.keySQL(),
+		rollupQuery(table, preWhere, where, precisions, rollupFunction),
+	)
+}
+
+// canPushdown returns true if aggregation of leaf is evaluated by clickhouse. Aggregation is pushed down for
+// targets with more series than aggregation-pushdown-threshold if all series have the same rollup rule.
+// Data of carbonlink is not aggregated by clickhouse, so pushdown is disabled with carbonlink
+func (h *Handler) canPushdown(p *aggregationPushdown, paths []string, rollupObj *rollup.Rollup, isReverse bool) bool {
+	threshold := h.config.ClickHouse.AggregationPushdownThreshold
+	if threshold <= 0 || len(paths) <= threshold || h.carbonlink != nil {
+		return false
+	}
+
+	// names of reversed or prefixed series are different from paths in points table
+	if len(p.tags) > 0 && (isReverse || h.config.ClickHouse.ExtraPrefix != "") {
+		return false
+	}
+
+	pattern := rollupObj.Match(paths[0])
+	if _, ok := aggregationSQL[pattern.Function]; !ok {
+		return false
+	}
+	for _, path := range paths[1:] {
+		if rollupObj.Match(path) != pattern {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cacheTarget returns target of aggregated series in render cache key
+func (p *aggregationPushdown) cacheTarget() string {
+	return fmt.Sprintf("pushdown:%s(%s):%s", p.function, p.keySQL(), p.leaf)
+}
+
+// fetchPushdown executes aggregation in clickhouse and returns aggregated series with the same step as rollup of
+// each aggregated series. Aggregated points are cached in render cache
+func (h *Handler) fetchPushdown(ctx context.Context, p *aggregationPushdown, paths []string, pointsTable string, isReverse bool, rollupObj *rollup.Rollup, fromTimestamp int64, untilTimestamp int64) ([]*series, error) {
+	from, until := uint32(fromTimestamp), uint32(untilTimestamp)
+
+	pattern := rollupObj.Match(paths[0])
+	step := rollupObj.Step(paths[0], from)
+	precisions := pattern.Precisions(from)
+	if len(precisions) == 0 || precisions[len(precisions)-1] != step {
+		// points must be rolled up to step before aggregation across series
+		precisions = append(precisions, step)
+	}
+
+	if isReverse {
+		reversed := make([]string, len(paths))
+		for i, path := range paths {
+			reversed[i] = reversePath(path)
+		}
+		paths = reversed
+	}
+
+	preWhere := finder.NewWhere()
+	preWhere.Andf(
+		"Date >='%s' AND Date <= '%s'",
+		time.Unix(fromTimestamp, 0).Format("2006-01-02"),
+		time.Unix(untilTimestamp, 0).Format("2006-01-02"),
+	)
+
+	pathIn, extData := PathIn(paths, h.config.ClickHouse.ExternalDataThreshold)
+	where := finder.NewWhere()
+	where.And(pathIn)
+	where.Andf("Time >= %d AND Time <= %d", fromTimestamp, untilTimestamp-untilTimestamp%int64(step)+int64(step)-1)
+
+	query := p.query(pointsTable, preWhere.String(), where.String(), precisions, pattern.Function)
+	if err := finder.CheckLimit("max-query-size", len(query), h.config.Limits.MaxQuerySize); err != nil {
+		return nil, err
+	}
+
+	opts := clickhouse.Options{Timeout: h.config.ClickHouse.DataTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()}
+	open := func(ctx context.Context, req dataRequest) (io.ReadCloser, error) {
+		return req.reader(ctx, h.config.ClickHouse.Url, pointsTable, opts)
+	}
+
+	fetch := func(ctx context.Context) (*Data, error) {
+		data, err := fetchConcurrent(ctx, []dataRequest{{query: query, extData: extData}}, open, func() *point.Points { return nil }, false)
+		if err != nil {
+			return nil, err
+		}
+		data.Points.Sort()
+		return data, nil
+	}
+
+	var data *Data
+	var err error
+	if h.config.RenderCache.Cache != nil {
+		key := renderCacheKey([]string{p.cacheTarget()}, fromTimestamp, untilTimestamp, pointsTable, 0)
+		data, err = h.cachedFetch(ctx, key, fetch)
+	} else {
+		data, err = fetch(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := stepBounds(from, until, step)
+	result := make([]*series, 0)
+
+	appendSeries := func(points []point.Point) {
+		name := data.Points.MetricName(points[0].MetricID)
+		var values []float64
+		if end >= start {
+			values = make([]float64, 0, (end-start)/step+1)
+		}
+		alignValues(points, start, end, step, func(t uint32, value float64, isAbsent bool) {
+			if isAbsent {
+				values = append(values, math.NaN())
+			} else {
+				values = append(values, value)
+			}
+		})
+		result = append(result, &series{name: name, pathExpression: name, start: start, step: step, values: values})
+	}
+
+	points := data.Points.List()
+	if len(points) == 0 {
+		return result, nil
+	}
+
+	// group by Metric
+	var i, n int
+	// i - current position of iterator
+	// n - position of the first record with current metric
+	l := len(points)
+
+	for i = 1; i < l; i++ {
+		if points[i].MetricID != points[n].MetricID {
+			appendSeries(points[n:i])
+			n = i
+			continue
+		}
+	}
+	appendSeries(points[n:i])
+
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+
+	return result, nil
+}
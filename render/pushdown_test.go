@@ -0,0 +1,265 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-graphite/carbonapi/pkg/parser"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/RowBinary"
+	"github.com/lomik/graphite-clickhouse/helper/cache"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestParsePushdown(t *testing.T) {
+	assert := assert.New(t)
+
+	table := []struct {
+		target string
+		leaf   string
+		fn     string
+		key    string
+	}{
+		{"sumSeries(a.*.cpu)", "a.*.cpu", "sum", "'sumSeries(a.*.cpu)'"},
+		{"averageSeries(seriesByTag('name=cpu'))", "seriesByTag('name=cpu')", "avg", "'averageSeries(seriesByTag(\\'name=cpu\\'))'"},
+		{"aggregate(a.*.cpu, 'count')", "a.*.cpu", "count", "'countSeries(a.*.cpu)'"},
+		{"groupByTags(seriesByTag('name=cpu'), 'max', 'dc')", "seriesByTag('name=cpu')", "max",
+			"concat('maxSeries', ';dc=', decodeURLComponent(extractURLParameter(Path, 'dc')))"},
+		{"groupByTags(seriesByTag('name=cpu'), 'sumSeries', 'name')", "seriesByTag('name=cpu')", "sum",
+			"decodeURLComponent(splitByChar('?', Path)[1])"},
+		// not supported
+		{"sumSeries(a.*.cpu, b.*.cpu)", "", "", ""},
+		{"sumSeries(scale(a.*.cpu, 2))", "", "", ""},
+		{"aggregate(a.*.cpu, 'last')", "", "", ""},
+		{"scale(a.*.cpu, 2)", "", "", ""},
+		{"groupByTags(seriesByTag('name=cpu'), 'sum')", "", "", ""},
+	}
+
+	for _, test := range table {
+		e, _, err := parser.Parse(test.target)
+		if !assert.NoError(err, test.target) {
+			continue
+		}
+
+		p := parsePushdown(e)
+		if test.leaf == "" {
+			assert.Nil(p, test.target)
+			continue
+		}
+		if assert.NotNil(p, test.target) {
+			assert.Equal(test.leaf, p.leaf, test.target)
+			assert.Equal(test.fn, p.function, test.target)
+			assert.Equal(test.key, p.keySQL(), test.target)
+		}
+	}
+}
+
+func TestRenderPushdown(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 179
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		switch {
+		case bytes.Contains(query, []byte("graphite_tree")):
+			return []byte("a.b.c\na.b.d\n")
+		case bytes.Contains(query, []byte("GROUP BY K, Time")):
+			// already aggregated by clickhouse
+			return makeData([]testPoint{
+				{"sumSeries(a.b.*)", 13, from, 10},
+				{"sumSeries(a.b.*)", 20, from + 120, 10},
+			})
+		default:
+			return makeData([]testPoint{
+				{"a.b.c", 3, from, 10},
+				{"a.b.d", 10, from, 10},
+				{"a.b.d", 20, from + 120, 10},
+			})
+		}
+	})
+
+	render := func(threshold int) string {
+		cfg := config.New()
+		cfg.ClickHouse.Url = srv.URL
+		cfg.ClickHouse.AggregationPushdownThreshold = threshold
+		cfg.Rollup = r
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=sumSeries(a.b.*)&from=%d&until=%d&format=json", from, until), nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+
+		assert.Equal(200, w.Code)
+		return w.Body.String()
+	}
+
+	expected := `[{"target":"sumSeries(a.b.*)","tags":{"name":"sumSeries(a.b.*)"},"datapoints":[[13,1520056800],[null,1520056860],[20,1520056920]]}]`
+
+	// 2 series are not more than threshold: aggregated in reply
+	assert.Equal(expected, render(2))
+	requests := srv.Requests()
+	assert.NotContains(string(requests[len(requests)-1].Query), "GROUP BY K, Time")
+
+	// aggregated by clickhouse
+	assert.Equal(expected, render(1))
+	requests = srv.Requests()
+	query := string(requests[len(requests)-1].Query)
+	assert.Contains(query, "toFloat64(sum(Value))")
+	assert.Contains(query, "'sumSeries(a.b.*)' AS K")
+	assert.Contains(query, "intDiv(Time, 60) * 60")
+	assert.Contains(query, "avg(Value) AS V")
+	assert.Contains(query, "Path in ('a.b.c','a.b.d')")
+}
+
+func TestRenderPushdownCount(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 119
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\na.b.d\n")
+		}
+
+		// count is UInt64 in clickhouse, so it must be converted to Float64 read by parser
+		if !bytes.Contains(query, []byte("toFloat64(count(Value))")) {
+			return nil
+		}
+
+		buf := new(bytes.Buffer)
+		w := RowBinary.NewEncoder(buf)
+		for i, v := range []float64{2, 1} {
+			w.String("countSeries(a.b.*)")
+			w.Uint32(from + uint32(i)*60)
+			w.Float64(v)
+			w.Uint32(10)
+		}
+		return buf.Bytes()
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.AggregationPushdownThreshold = 1
+	cfg.Rollup = r
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=countSeries(a.b.*)&from=%d&until=%d&format=json", from, until), nil)
+	w := httptest.NewRecorder()
+	NewHandler(cfg).ServeHTTP(w, req)
+
+	assert.Equal(200, w.Code)
+	assert.Equal(`[{"target":"countSeries(a.b.*)","tags":{"name":"countSeries(a.b.*)"},"datapoints":[[2,1520056800],[1,1520056860]]}]`, w.Body.String())
+}
+
+func TestRenderPushdownLimitsAndCache(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 179
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\na.b.d\n")
+		}
+		return makeData([]testPoint{
+			{"sumSeries(a.b.*)", 13, from, 10},
+			{"sumSeries(a.b.*)", 20, from + 120, 10},
+		})
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.AggregationPushdownThreshold = 1
+	cfg.Rollup = r
+
+	render := func() (int, string) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=sumSeries(a.b.*)&from=%d&until=%d&format=json", from, until), nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	aggregations := func() int {
+		n := 0
+		for _, req := range srv.Requests() {
+			if bytes.Contains(req.Query, []byte("GROUP BY K, Time")) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// 2 series * 3 points are read by clickhouse
+	cfg.Limits.MaxPointsPerRender = 5
+	code, body := render()
+	assert.Equal(400, code)
+	assert.Contains(body, "max-points-per-render")
+
+	cfg.Limits.MaxPointsPerRender = 0
+	cfg.Limits.MaxMetricsPerRender = 1
+	code, body = render()
+	assert.Equal(400, code)
+	assert.Contains(body, "max-metrics-per-render")
+	assert.Equal(0, aggregations())
+
+	cfg.Limits.MaxMetricsPerRender = 0
+	cfg.RenderCache.Cache = cache.New(1024 * 1024)
+
+	expected := `[{"target":"sumSeries(a.b.*)","tags":{"name":"sumSeries(a.b.*)"},"datapoints":[[13,1520056800],[null,1520056860],[20,1520056920]]}]`
+	for i := 0; i < 2; i++ {
+		code, body = render()
+		assert.Equal(200, code)
+		assert.Equal(expected, body)
+	}
+	assert.Equal(1, aggregations())
+}
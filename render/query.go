@@ -0,0 +1,118 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+// dataRequest is query to points table with optional external data table of paths
+type dataRequest struct {
+	query   string
+	extData *clickhouse.ExternalData
+}
+
+func (r dataRequest) reader(ctx context.Context, dsn string, table string, opts clickhouse.Options) (io.ReadCloser, error) {
+	if r.extData != nil {
+		return clickhouse.ReaderWithExternalData(ctx, dsn, r.query, table, r.extData, opts)
+	}
+	return clickhouse.Reader(ctx, dsn, r.query, table, opts)
+}
+
+// PathIn returns condition "Path IN (...)" with quoted paths.
+// If threshold > 0 and count of paths is more than threshold paths are sent as external data table _data
+// and condition is "Path IN _data"
+func PathIn(paths []string, threshold int) (string, *clickhouse.ExternalData) {
+	if threshold > 0 && len(paths) > threshold {
+		extData := clickhouse.NewExternalData("_data", "Path String")
+		for _, p := range paths {
+			extData.AppendRow(p)
+		}
+		return "Path IN _data", extData
+	}
+
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = "'" + clickhouse.Escape(p) + "'"
+	}
+
+	return fmt.Sprintf("Path in (%s)", strings.Join(quoted, ",")), nil
+}
+
+// aggregationSQL is clickhouse expression for rollup function over deduplicated points
+var aggregationSQL = map[string]string{
+	"avg":     "avg(Value)",
+	"max":     "max(Value)",
+	"min":     "min(Value)",
+	"sum":     "sum(Value)",
+	"any":     "argMin(Value, Time)",
+	"anyLast": "argMax(Value, Time)",
+}
+
+// dataQuery returns query for points table. Result columns are Path, Time, Value, Timestamp in RowBinary.
+// If precisions is not empty points are deduplicated by Timestamp and then aggregated with rollup function
+// by intDiv(Time, precision) for each precision one after another, same as rollup in reply does.
+// If sorted is true rows are ordered by Path and Time
+func dataQuery(table string, preWhere string, where string, precisions []uint32, function string, sorted bool) string {
+	suffix := "FORMAT RowBinary\n"
+	if sorted {
+		suffix = "ORDER BY Path, Time\n" + suffix
+	}
+
+	return rollupQuery(table, preWhere, where, precisions, function) + suffix
+}
+
+// rollupQuery returns subquery of dataQuery with columns Path, Time, Value, Timestamp without FORMAT
+func rollupQuery(table string, preWhere string, where string, precisions []uint32, function string) string {
+	if len(precisions) == 0 {
+		return fmt.Sprintf(
+			`
+			SELECT
+				Path, Time, Value, Timestamp
+			FROM %s
+			PREWHERE (%s)
+			WHERE (%s)
+			`,
+			table,
+			preWhere,
+			where,
+		)
+	}
+
+	query := fmt.Sprintf(
+		`
+		SELECT
+			Path, Time, argMax(Value, Timestamp) AS Value, max(Timestamp) AS Timestamp
+		FROM %s
+		PREWHERE (%s)
+		WHERE (%s)
+		GROUP BY Path, Time
+		`,
+		table,
+		preWhere,
+		where,
+	)
+
+	for _, precision := range precisions {
+		query = fmt.Sprintf(
+			`
+			SELECT
+				Path, T AS Time, V AS Value, TS AS Timestamp
+			FROM (
+				SELECT
+					Path, toUInt32(intDiv(Time, %d) * %d) AS T, %s AS V, max(Timestamp) AS TS
+				FROM (%s)
+				GROUP BY Path, T
+			)
+			`,
+			precision, precision,
+			aggregationSQL[function],
+			query,
+		)
+	}
+
+	return query
+}
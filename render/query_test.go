@@ -0,0 +1,61 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	table := []struct {
+		precisions []uint32
+		function   string
+		contains   []string
+	}{
+		{nil, "", []string{"Path, Time, Value, Timestamp\n", "FROM graphite"}},
+		{[]uint32{600}, "avg", []string{"argMax(Value, Timestamp) AS Value", "intDiv(Time, 600) * 600", "avg(Value) AS V", "GROUP BY Path, T\n"}},
+		{[]uint32{60}, "max", []string{"intDiv(Time, 60) * 60", "max(Value) AS V"}},
+		{[]uint32{60}, "anyLast", []string{"argMax(Value, Time) AS V"}},
+		{[]uint32{60}, "any", []string{"argMin(Value, Time) AS V"}},
+		{[]uint32{60, 300}, "sum", []string{"intDiv(Time, 60) * 60", "intDiv(Time, 300) * 300", "sum(Value) AS V"}},
+	}
+
+	for _, test := range table {
+		query := dataQuery("graphite", "Date >= '2018-01-01'", "Path in ('a.b')", test.precisions, test.function, false)
+		assert.True(strings.HasSuffix(strings.TrimSpace(query), "FORMAT RowBinary"))
+		for _, s := range test.contains {
+			assert.Contains(query, s, "precisions %v, function %s", test.precisions, test.function)
+		}
+		if len(test.precisions) > 1 {
+			// the first precision is applied first, in the deepest subquery
+			assert.True(strings.Index(query, "intDiv(Time, 300)") < strings.Index(query, "intDiv(Time, 60)"))
+		}
+		assert.NotContains(query, "ORDER BY")
+
+		query = dataQuery("graphite", "Date >= '2018-01-01'", "Path in ('a.b')", test.precisions, test.function, true)
+		assert.True(strings.HasSuffix(strings.TrimSpace(query), "ORDER BY Path, Time\nFORMAT RowBinary"))
+	}
+}
+
+func TestPathIn(t *testing.T) {
+	assert := assert.New(t)
+
+	cond, extData := PathIn([]string{"a.b", "c'd"}, 2)
+	assert.Equal("Path in ('a.b','c\\'d')", cond)
+	assert.Nil(extData)
+
+	cond, extData = PathIn([]string{"a.b", "c.d", "e.f"}, 2)
+	assert.Equal("Path IN _data", cond)
+	if assert.NotNil(extData) {
+		assert.Equal("Path String", extData.Structure)
+		assert.Equal(len("a.b\nc.d\ne.f\n"), extData.Len())
+	}
+
+	// threshold 0 disables external data
+	cond, extData = PathIn([]string{"a.b", "c.d", "e.f"}, 0)
+	assert.Equal("Path in ('a.b','c.d','e.f')", cond)
+	assert.Nil(extData)
+}
@@ -0,0 +1,187 @@
+package render
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+// parseCarbonapiV3Request reads MultiFetchRequest from request body.
+// Request without body is made from target, from, until and maxDataPoints parameters
+func parseCarbonapiV3Request(r *http.Request) (*carbonapi_v3_pb.MultiFetchRequest, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &carbonapi_v3_pb.MultiFetchRequest{}
+
+	if len(body) > 0 {
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, fmt.Errorf("Bad request (malformed MultiFetchRequest: %s)", err.Error())
+		}
+		return req, nil
+	}
+
+	from, err := strconv.ParseInt(r.FormValue("from"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Bad request (malformed from)")
+	}
+
+	until, err := strconv.ParseInt(r.FormValue("until"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Bad request (malformed until)")
+	}
+
+	var maxDataPoints int64
+	if r.FormValue("maxDataPoints") != "" {
+		maxDataPoints, err = strconv.ParseInt(r.FormValue("maxDataPoints"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Bad request (malformed maxDataPoints)")
+		}
+	}
+
+	for _, target := range r.Form["target"] {
+		req.Metrics = append(req.Metrics, &carbonapi_v3_pb.FetchRequest{
+			Name:           target,
+			PathExpression: target,
+			StartTime:      from,
+			StopTime:       until,
+			MaxDataPoints:  maxDataPoints,
+		})
+	}
+
+	return req, nil
+}
+
+// fetchRange is time range and maxDataPoints of targets fetched together
+type fetchRange struct {
+	from          int64
+	until         int64
+	maxDataPoints int
+}
+
+// serveCarbonapiV3 replies to carbonapi v3 MultiFetchRequest. Every target has own time range and
+// maxDataPoints, targets with equal ones are fetched together. Graphite functions are evaluated by carbonapi,
+// so targets with functions are rejected
+func (h *Handler) serveCarbonapiV3(w http.ResponseWriter, r *http.Request) {
+	req, err := parseCarbonapiV3Request(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ranges := make([]fetchRange, 0)
+	targets := make(map[fetchRange][]string)
+
+	for _, m := range req.Metrics {
+		target := m.PathExpression
+		if target == "" {
+			target = m.Name
+		}
+		if target == "" {
+			continue
+		}
+		if IsExprTarget(target) {
+			http.Error(w, fmt.Sprintf("Bad request (functions are not supported in carbonapi_v3_pb format: %#v)", target), http.StatusBadRequest)
+			return
+		}
+
+		fr := fetchRange{from: m.StartTime, until: m.StopTime, maxDataPoints: int(m.MaxDataPoints)}
+		if _, ok := targets[fr]; !ok {
+			ranges = append(ranges, fr)
+		}
+		targets[fr] = append(targets[fr], target)
+	}
+
+	response := &carbonapi_v3_pb.MultiFetchResponse{}
+
+	for _, fr := range ranges {
+		data, rollupObj, ok := h.render(w, r, targets[fr], fr.from, fr.until, fr.maxDataPoints, false)
+		if !ok {
+			return
+		}
+
+		response.Metrics = append(response.Metrics, data.carbonapiV3(uint32(fr.from), uint32(fr.until), rollupObj)...)
+	}
+
+	body, err := proto.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(body)
+}
+
+// carbonapiV3 returns FetchResponse for every alias of every metric. Absent values are NaN
+func (d *Data) carbonapiV3(from, until uint32, rollupObj *rollup.Rollup) []*carbonapi_v3_pb.FetchResponse {
+	points := d.Points.List()
+	result := make([]*carbonapi_v3_pb.FetchResponse, 0)
+
+	if len(points) == 0 {
+		return result
+	}
+
+	writeMetric := func(points []point.Point) {
+		metric := d.Points.MetricName(points[0].MetricID)
+		points, step := d.rollupMetric(rollupObj, from, until, points)
+
+		start, stop := stepBounds(from, until, step)
+
+		// no step boundary between from and until
+		var values []float64
+		if stop >= start {
+			values = make([]float64, int((stop-start)/step)+1)
+		}
+		for i := range values {
+			values[i] = math.NaN()
+		}
+		for _, p := range points {
+			if p.Time < start || p.Time > stop {
+				continue
+			}
+			values[(p.Time-start)/step] = p.Value
+		}
+
+		a := d.Aliases[metric]
+		for k := 0; k < len(a); k += 2 {
+			result = append(result, &carbonapi_v3_pb.FetchResponse{
+				Name:              a[k],
+				PathExpression:    a[k+1],
+				ConsolidationFunc: rollupObj.Match(metric).Function,
+				StartTime:         int64(start),
+				StopTime:          int64(stop),
+				StepTime:          int64(step),
+				Values:            values,
+				RequestStartTime:  int64(from),
+				RequestStopTime:   int64(until),
+			})
+		}
+	}
+
+	// group by Metric
+	var i, n int
+	// i - current position of iterator
+	// n - position of the first record with current metric
+	l := len(points)
+
+	for i = 1; i < l; i++ {
+		if points[i].MetricID != points[n].MetricID {
+			writeMetric(points[n:i])
+			n = i
+		}
+	}
+	writeMetric(points[n:i])
+
+	return result
+}
@@ -0,0 +1,163 @@
+package render
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/carbonapi_v3_pb"
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestCarbonapiV3(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from int64 = 1520056800
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\n")
+		}
+		return makeData([]testPoint{
+			{"a.b.c", 1, uint32(from), 10},
+			{"a.b.c", 3, uint32(from + 120), 10},
+		})
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.Rollup = r
+
+	body, err := proto.Marshal(&carbonapi_v3_pb.MultiFetchRequest{
+		Metrics: []*carbonapi_v3_pb.FetchRequest{
+			{Name: "a.b.*", PathExpression: "a.b.*", StartTime: from, StopTime: from + 179},
+			{Name: "a.b.c", PathExpression: "a.b.c", StartTime: from, StopTime: from + 179, MaxDataPoints: 1},
+		},
+	})
+	assert.NoError(err)
+
+	req := httptest.NewRequest("POST", "/render/?format=carbonapi_v3_pb", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	NewHandler(cfg).ServeHTTP(w, req)
+
+	assert.Equal(200, w.Code, w.Body.String())
+
+	var response carbonapi_v3_pb.MultiFetchResponse
+	assert.NoError(proto.Unmarshal(w.Body.Bytes(), &response))
+
+	if assert.Len(response.Metrics, 2) {
+		m := response.Metrics[0]
+		assert.Equal("a.b.c", m.Name)
+		assert.Equal("a.b.*", m.PathExpression)
+		assert.Equal("avg", m.ConsolidationFunc)
+		assert.Equal(from, m.StartTime)
+		assert.Equal(from+120, m.StopTime)
+		assert.Equal(int64(60), m.StepTime)
+		assert.Equal(from, m.RequestStartTime)
+		assert.Equal(from+179, m.RequestStopTime)
+		if assert.Len(m.Values, 3) {
+			assert.Equal(1.0, m.Values[0])
+			assert.True(math.IsNaN(m.Values[1]))
+			assert.Equal(3.0, m.Values[2])
+		}
+
+		// consolidated to maxDataPoints
+		m = response.Metrics[1]
+		assert.Equal("a.b.c", m.PathExpression)
+		assert.Equal(int64(180), m.StepTime)
+		assert.Equal([]float64{2}, m.Values)
+	}
+}
+
+func TestCarbonapiV3Functions(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+
+	body, err := proto.Marshal(&carbonapi_v3_pb.MultiFetchRequest{
+		Metrics: []*carbonapi_v3_pb.FetchRequest{
+			{Name: "a.b.*", PathExpression: "a.b.*", StartTime: 1520056800, StopTime: 1520056980},
+			{Name: "sumSeries(a.b.*)", PathExpression: "sumSeries(a.b.*)", StartTime: 1520056800, StopTime: 1520056980},
+		},
+	})
+	assert.NoError(err)
+
+	req := httptest.NewRequest("POST", "/render/?format=carbonapi_v3_pb", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	NewHandler(cfg).ServeHTTP(w, req)
+
+	assert.Equal(400, w.Code)
+	assert.Contains(w.Body.String(), "sumSeries(a.b.*)")
+	assert.Empty(srv.Requests())
+
+	// seriesByTag is not evaluated
+	body, err = proto.Marshal(&carbonapi_v3_pb.MultiFetchRequest{
+		Metrics: []*carbonapi_v3_pb.FetchRequest{
+			{Name: "seriesByTag('name=cpu')", PathExpression: "seriesByTag('name=cpu')", StartTime: 1520056800, StopTime: 1520056980},
+		},
+	})
+	assert.NoError(err)
+
+	req = httptest.NewRequest("POST", "/render/?format=carbonapi_v3_pb", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	NewHandler(cfg).ServeHTTP(w, req)
+
+	assert.Equal(200, w.Code, w.Body.String())
+}
+
+func TestCarbonapiV3ShortRange(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056810
+
+	d := &Data{Points: point.NewPoints()}
+	id := d.Points.MetricID("a.b.c")
+	d.Points.AppendPoint(id, 1, from-10, from)
+	d.Points.AppendPoint(id, 2, from+50, from)
+	d.Aliases = map[string][]string{"a.b.c": {"a.b.c", "a.b.c"}}
+
+	// until-from is smaller than step and there is no step boundary in range
+	result := d.carbonapiV3(from, from+30, r)
+	if assert.Len(result, 1) {
+		assert.Empty(result[0].Values)
+		assert.Equal(int64(60), result[0].StepTime)
+	}
+}
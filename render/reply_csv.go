@@ -0,0 +1,89 @@
+package render
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+// csvEncoder writes rows "name,YYYY-MM-DD HH:MM:SS,value" like graphite-web. Value is empty for absent points
+type csvEncoder struct {
+	c           *csv.Writer
+	record      []string
+	from, until uint32
+}
+
+func newCSVEncoder(w io.Writer, from, until uint32) *csvEncoder {
+	return &csvEncoder{c: csv.NewWriter(w), record: make([]string, 3), from: from, until: until}
+}
+
+func (e *csvEncoder) start() {}
+
+func (e *csvEncoder) writeMetric(name string, pathExpression string, points []point.Point, step uint32) {
+	start, end := stepBounds(e.from, e.until, step)
+
+	record := e.record
+	record[0] = name
+	alignValues(points, start, end, step, func(t uint32, value float64, isAbsent bool) {
+		record[1] = time.Unix(int64(t), 0).Format("2006-01-02 15:04:05")
+		if isAbsent {
+			record[2] = ""
+		} else {
+			record[2] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		e.c.Write(record)
+	})
+}
+
+func (e *csvEncoder) finish() {
+	e.c.Flush()
+}
+
+// ReplyCSV writes data with csvEncoder
+func (h *Handler) ReplyCSV(w http.ResponseWriter, r *http.Request, data *Data, from, until uint32, prefix string, rollupObj *rollup.Rollup) {
+	points := data.Points.List()
+
+	w.Header().Set("Content-Type", "text/csv")
+
+	if len(points) == 0 {
+		return
+	}
+
+	writer := bufio.NewWriterSize(w, 1024*1024)
+	defer writer.Flush()
+
+	e := newCSVEncoder(writer, from, until)
+	defer e.finish()
+
+	writeMetric := func(name string, points []point.Point) {
+		points, step := data.rollupMetric(rollupObj, from, until, points)
+		e.writeMetric(name, "", points, step)
+	}
+
+	// group by Metric
+	var i, n, k int
+	// i - current position of iterator
+	// n - position of the first record with current metric
+	l := len(points)
+
+	for i = 1; i < l; i++ {
+		if points[i].MetricID != points[n].MetricID {
+			a := data.Aliases[data.Points.MetricName(points[n].MetricID)]
+			for k = 0; k < len(a); k += 2 {
+				writeMetric(a[k], points[n:i])
+			}
+			n = i
+			continue
+		}
+	}
+	a := data.Aliases[data.Points.MetricName(points[n].MetricID)]
+	for k = 0; k < len(a); k += 2 {
+		writeMetric(a[k], points[n:i])
+	}
+}
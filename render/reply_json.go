@@ -0,0 +1,191 @@
+package render
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/helper/log"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+	"go.uber.org/zap"
+)
+
+// alignValues calls cb for every step in [start, end] with value of point or with isAbsent=true.
+// Alignment is the same as in ReplyPickle and ReplyProtobuf
+func alignValues(points []point.Point, start, end, step uint32, cb func(t uint32, value float64, isAbsent bool)) {
+	last := start - step
+	for _, p := range points {
+		if p.Time < start || p.Time > end {
+			continue
+		}
+
+		for t := last + step; t < p.Time; t += step {
+			cb(t, 0, true)
+		}
+
+		cb(p.Time, p.Value, false)
+		last = p.Time
+	}
+
+	for t := last + step; t <= end; t += step {
+		cb(t, 0, true)
+	}
+}
+
+// stepBounds returns first and last timestamps in [from, until] aligned to step
+func stepBounds(from, until, step uint32) (uint32, uint32) {
+	start := from - (from % step)
+	if start < from {
+		start += step
+	}
+	end := until - (until % step)
+
+	return start, end
+}
+
+// graphiteTags returns tags of series name in graphite-web format
+// "name;k1=v1;k2=v2" -> {"name": "name", "k1": "v1", "k2": "v2"}
+func graphiteTags(name string) map[string]string {
+	a := strings.Split(name, ";")
+	tags := make(map[string]string, len(a))
+	tags["name"] = a[0]
+
+	for i := 1; i < len(a); i++ {
+		kv := strings.SplitN(a[i], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		tags[kv[0]] = kv[1]
+	}
+
+	return tags
+}
+
+// jsonEncoder writes list of metrics in graphite-web json format
+type jsonEncoder struct {
+	writer      *bufio.Writer
+	from, until uint32
+	first       bool
+}
+
+func newJSONEncoder(w *bufio.Writer, from, until uint32) *jsonEncoder {
+	return &jsonEncoder{writer: w, from: from, until: until, first: true}
+}
+
+func (e *jsonEncoder) start() {
+	e.writer.WriteByte('[')
+}
+
+func (e *jsonEncoder) writeMetric(name string, pathExpression string, points []point.Point, step uint32) {
+	writer := e.writer
+
+	if !e.first {
+		writer.WriteByte(',')
+	}
+	e.first = false
+
+	writer.WriteString(`{"target":`)
+	b, _ := json.Marshal(name)
+	writer.Write(b)
+
+	writer.WriteString(`,"tags":`)
+	b, _ = json.Marshal(graphiteTags(name))
+	writer.Write(b)
+
+	writer.WriteString(`,"datapoints":[`)
+
+	start, end := stepBounds(e.from, e.until, step)
+
+	firstValue := true
+	alignValues(points, start, end, step, func(t uint32, value float64, isAbsent bool) {
+		if !firstValue {
+			writer.WriteByte(',')
+		}
+		firstValue = false
+
+		writer.WriteByte('[')
+		if isAbsent || math.IsNaN(value) || math.IsInf(value, 0) {
+			writer.WriteString("null")
+		} else {
+			writer.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		}
+		writer.WriteByte(',')
+		writer.WriteString(strconv.FormatUint(uint64(t), 10))
+		writer.WriteByte(']')
+	})
+
+	writer.WriteString("]}")
+}
+
+func (e *jsonEncoder) finish() {
+	e.writer.WriteByte(']')
+}
+
+func (h *Handler) ReplyJSON(w http.ResponseWriter, r *http.Request, data *Data, from, until uint32, prefix string, rollupObj *rollup.Rollup) {
+	var rollupTime time.Duration
+	var jsonTime time.Duration
+
+	points := data.Points.List()
+
+	defer func() {
+		log.FromContext(r.Context()).Debug("rollup",
+			zap.String("runtime", rollupTime.String()),
+			zap.Duration("runtime_ns", rollupTime),
+		)
+		log.FromContext(r.Context()).Debug("json",
+			zap.String("runtime", jsonTime.String()),
+			zap.Duration("runtime_ns", jsonTime),
+		)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(points) == 0 {
+		w.Write([]byte("[]"))
+		return
+	}
+
+	writer := bufio.NewWriterSize(w, 1024*1024)
+	defer writer.Flush()
+
+	e := newJSONEncoder(writer, from, until)
+	e.start()
+
+	writeMetric := func(name string, pathExpression string, points []point.Point) {
+		rollupStart := time.Now()
+		points, step := data.rollupMetric(rollupObj, from, until, points)
+		rollupTime += time.Since(rollupStart)
+
+		jsonStart := time.Now()
+		e.writeMetric(name, pathExpression, points, step)
+		jsonTime += time.Since(jsonStart)
+	}
+
+	// group by Metric
+	var i, n, k int
+	// i - current position of iterator
+	// n - position of the first record with current metric
+	l := len(points)
+
+	for i = 1; i < l; i++ {
+		if points[i].MetricID != points[n].MetricID {
+			a := data.Aliases[data.Points.MetricName(points[n].MetricID)]
+			for k = 0; k < len(a); k += 2 {
+				writeMetric(a[k], a[k+1], points[n:i])
+			}
+			n = i
+			continue
+		}
+	}
+	a := data.Aliases[data.Points.MetricName(points[n].MetricID)]
+	for k = 0; k < len(a); k += 2 {
+		writeMetric(a[k], a[k+1], points[n:i])
+	}
+
+	e.finish()
+}
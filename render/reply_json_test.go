@@ -0,0 +1,86 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestAlignValues(t *testing.T) {
+	assert := assert.New(t)
+
+	points := []point.Point{
+		{MetricID: 1, Time: 120, Value: 2},
+		{MetricID: 1, Time: 240, Value: 4},
+		{MetricID: 1, Time: 600, Value: 10},
+	}
+
+	var times []uint32
+	var values []interface{}
+
+	start, end := stepBounds(61, 359, 60)
+	assert.Equal(uint32(120), start)
+	assert.Equal(uint32(300), end)
+
+	alignValues(points, start, end, 60, func(t uint32, value float64, isAbsent bool) {
+		times = append(times, t)
+		if isAbsent {
+			values = append(values, nil)
+		} else {
+			values = append(values, value)
+		}
+	})
+
+	assert.Equal([]uint32{120, 180, 240, 300}, times)
+	assert.Equal([]interface{}{2.0, nil, 4.0, nil}, values)
+}
+
+func TestReplyJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	data := &Data{Points: point.NewPoints()}
+	id := data.Points.MetricID("hello.world")
+	data.Points.AppendPoint(id, 1, 60, 60)
+	data.Points.AppendPoint(id, 3, 90, 90)
+	data.Points.AppendPoint(id, 5, 180, 180)
+	data.Aliases = map[string][]string{
+		"hello.world": []string{"hello.world", "hello.*"},
+	}
+
+	h := NewHandler(config.New())
+	w := httptest.NewRecorder()
+	h.ReplyJSON(w, httptest.NewRequest("GET", "/render/?format=json", nil), data, 60, 179, "", r)
+
+	var response []struct {
+		Target     string            `json:"target"`
+		Tags       map[string]string `json:"tags"`
+		Datapoints [][2]*float64     `json:"datapoints"`
+	}
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(response, 1)
+	assert.Equal("hello.world", response[0].Target)
+	assert.Equal(map[string]string{"name": "hello.world"}, response[0].Tags)
+	assert.Len(response[0].Datapoints, 2)
+	assert.Equal(2.0, *response[0].Datapoints[0][0])
+	assert.Equal(60.0, *response[0].Datapoints[0][1])
+	assert.Nil(response[0].Datapoints[1][0])
+	assert.Equal(120.0, *response[0].Datapoints[1][1])
+}
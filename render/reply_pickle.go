@@ -2,6 +2,7 @@ package render
 
 import (
 	"bufio"
+	"io"
 	"net/http"
 	"time"
 
@@ -12,6 +13,80 @@ import (
 	"go.uber.org/zap"
 )
 
+// pickleEncoder writes list of metrics in pickle format
+type pickleEncoder struct {
+	p           *pickle.Writer
+	from, until uint32
+}
+
+func newPickleEncoder(w io.Writer, from, until uint32) *pickleEncoder {
+	return &pickleEncoder{p: pickle.NewWriter(w), from: from, until: until}
+}
+
+func (e *pickleEncoder) start() {
+	e.p.List()
+}
+
+func (e *pickleEncoder) writeMetric(name string, pathExpression string, points []point.Point, step uint32) {
+	p := e.p
+
+	p.Dict()
+
+	p.String("name")
+	p.String(name)
+	p.SetItem()
+
+	p.String("pathExpression")
+	p.String(pathExpression)
+	p.SetItem()
+
+	p.String("step")
+	p.Uint32(step)
+	p.SetItem()
+
+	start := e.from - (e.from % step)
+	if start < e.from {
+		start += step
+	}
+	end := e.until - (e.until % step)
+	last := start - step
+
+	p.String("values")
+	p.List()
+	for _, point := range points {
+		if point.Time < start || point.Time > end {
+			continue
+		}
+
+		if point.Time > last+step {
+			p.AppendNulls(int(((point.Time - last) / step) - 1))
+		}
+
+		p.AppendFloat64(point.Value)
+
+		last = point.Time
+	}
+
+	if end > last {
+		p.AppendNulls(int((end - last) / step))
+	}
+	p.SetItem()
+
+	p.String("start")
+	p.Uint32(uint32(start))
+	p.SetItem()
+
+	p.String("end")
+	p.Uint32(uint32(end))
+	p.SetItem()
+
+	p.Append()
+}
+
+func (e *pickleEncoder) finish() {
+	e.p.Stop()
+}
+
 func (h *Handler) ReplyPickle(w http.ResponseWriter, r *http.Request, data *Data, from, until uint32, prefix string, rollupObj *rollup.Rollup) {
 	var rollupTime time.Duration
 	var pickleTime time.Duration
@@ -35,68 +110,18 @@ func (h *Handler) ReplyPickle(w http.ResponseWriter, r *http.Request, data *Data
 	}
 
 	writer := bufio.NewWriterSize(w, 1024*1024)
-	p := pickle.NewWriter(writer)
+	e := newPickleEncoder(writer, from, until)
 	defer writer.Flush()
 
-	p.List()
+	e.start()
 
 	writeMetric := func(name string, pathExpression string, points []point.Point) {
 		rollupStart := time.Now()
-		points, step := rollupObj.RollupMetric(data.Points.MetricName(points[0].MetricID), from, points)
+		points, step := data.rollupMetric(rollupObj, from, until, points)
 		rollupTime += time.Since(rollupStart)
 
 		pickleStart := time.Now()
-		p.Dict()
-
-		p.String("name")
-		p.String(name)
-		p.SetItem()
-
-		p.String("pathExpression")
-		p.String(pathExpression)
-		p.SetItem()
-
-		p.String("step")
-		p.Uint32(step)
-		p.SetItem()
-
-		start := from - (from % step)
-		if start < from {
-			start += step
-		}
-		end := until - (until % step)
-		last := start - step
-
-		p.String("values")
-		p.List()
-		for _, point := range points {
-			if point.Time < start || point.Time > end {
-				continue
-			}
-
-			if point.Time > last+step {
-				p.AppendNulls(int(((point.Time - last) / step) - 1))
-			}
-
-			p.AppendFloat64(point.Value)
-
-			last = point.Time
-		}
-
-		if end > last {
-			p.AppendNulls(int((end - last) / step))
-		}
-		p.SetItem()
-
-		p.String("start")
-		p.Uint32(uint32(start))
-		p.SetItem()
-
-		p.String("end")
-		p.Uint32(uint32(end))
-		p.SetItem()
-
-		p.Append()
+		e.writeMetric(name, pathExpression, points, step)
 		pickleTime += time.Since(pickleStart)
 	}
 
@@ -122,5 +147,5 @@ func (h *Handler) ReplyPickle(w http.ResponseWriter, r *http.Request, data *Data
 		writeMetric(a[k], a[k+1], points[n:i])
 	}
 
-	p.Stop()
+	e.finish()
 }
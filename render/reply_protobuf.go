@@ -9,108 +9,126 @@ import (
 	"github.com/lomik/graphite-clickhouse/helper/rollup"
 )
 
-func (h *Handler) ReplyProtobuf(w http.ResponseWriter, r *http.Request, data *Data, from, until uint32, prefix string, rollupObj *rollup.Rollup) {
-	points := data.Points.List()
-
-	if len(points) == 0 {
-		return
-	}
+// protobufEncoder writes metrics as carbonzipper MultiFetchResponse
+type protobufEncoder struct {
+	writer      *bufio.Writer
+	mb          *bytes.Buffer
+	from, until uint32
+}
 
-	// var multiResponse carbonzipperpb.MultiFetchResponse
-	writer := bufio.NewWriterSize(w, 1024*1024)
-	defer writer.Flush()
+func newProtobufEncoder(w *bufio.Writer, from, until uint32) *protobufEncoder {
+	return &protobufEncoder{writer: w, mb: new(bytes.Buffer), from: from, until: until}
+}
 
-	mb := new(bytes.Buffer)
+func (e *protobufEncoder) start() {}
 
-	writeMetric := func(name string, points []point.Point) {
-		points, step := rollupObj.RollupMetric(data.Points.MetricName(points[0].MetricID), from, points)
+func (e *protobufEncoder) writeMetric(name string, pathExpression string, points []point.Point, step uint32) {
+	start := e.from - (e.from % step)
+	if start < e.from {
+		start += step
+	}
+	stop := e.until - (e.until % step)
+	count := ((stop - start) / step) + 1
+
+	e.mb.Reset()
+
+	// name
+	VarintWrite(e.mb, (1<<3)+2) // tag
+	VarintWrite(e.mb, uint64(len(name)))
+	e.mb.WriteString(name)
+
+	// start
+	VarintWrite(e.mb, 2<<3)
+	VarintWrite(e.mb, uint64(start))
+
+	// stop
+	VarintWrite(e.mb, 3<<3)
+	VarintWrite(e.mb, uint64(stop))
+
+	// step
+	VarintWrite(e.mb, 4<<3)
+	VarintWrite(e.mb, uint64(step))
+
+	// start write to output
+
+	// repeated FetchResponse metrics = 1;
+	// write tag and len
+	VarintWrite(e.writer, (1<<3)+2)
+	VarintWrite(e.writer,
+		uint64(e.mb.Len())+
+			2+ // tags of <repeated double values = 5;> and <repeated bool isAbsent = 6;>
+			VarintLen(uint64(8*count))+ // len of packed <repeated double values>
+			VarintLen(uint64(count))+ // len of packed <repeated bool isAbsent>
+			uint64(9*count), // packed <repeated double values> and <repeated bool isAbsent>
+	)
+
+	e.writer.Write(e.mb.Bytes())
+
+	// Write values
+	VarintWrite(e.writer, (5<<3)+2)
+	VarintWrite(e.writer, uint64(8*count))
+
+	last := start - step
+	for _, point := range points {
+		if point.Time < start || point.Time > stop {
+			continue
+		}
 
-		start := from - (from % step)
-		if start < from {
-			start += step
+		if point.Time > last+step {
+			ProtobufWriteDoubleN(e.writer, 0, int(((point.Time-last)/step)-1))
 		}
-		stop := until - (until % step)
-		count := ((stop - start) / step) + 1
-
-		mb.Reset()
-
-		// name
-		VarintWrite(mb, (1<<3)+2) // tag
-		VarintWrite(mb, uint64(len(name)))
-		mb.WriteString(name)
-
-		// start
-		VarintWrite(mb, 2<<3)
-		VarintWrite(mb, uint64(start))
-
-		// stop
-		VarintWrite(mb, 3<<3)
-		VarintWrite(mb, uint64(stop))
-
-		// step
-		VarintWrite(mb, 4<<3)
-		VarintWrite(mb, uint64(step))
-
-		// start write to output
-
-		// repeated FetchResponse metrics = 1;
-		// write tag and len
-		VarintWrite(writer, (1<<3)+2)
-		VarintWrite(writer,
-			uint64(mb.Len())+
-				2+ // tags of <repeated double values = 5;> and <repeated bool isAbsent = 6;>
-				VarintLen(uint64(8*count))+ // len of packed <repeated double values>
-				VarintLen(uint64(count))+ // len of packed <repeated bool isAbsent>
-				uint64(9*count), // packed <repeated double values> and <repeated bool isAbsent>
-		)
-
-		writer.Write(mb.Bytes())
-
-		// Write values
-		VarintWrite(writer, (5<<3)+2)
-		VarintWrite(writer, uint64(8*count))
-
-		last := start - step
-		for _, point := range points {
-			if point.Time < start || point.Time > stop {
-				continue
-			}
 
-			if point.Time > last+step {
-				ProtobufWriteDoubleN(writer, 0, int(((point.Time-last)/step)-1))
-			}
+		ProtobufWriteDouble(e.writer, point.Value)
+
+		last = point.Time
+	}
+
+	if stop > last {
+		ProtobufWriteDoubleN(e.writer, 0, int((stop-last)/step))
+	}
 
-			ProtobufWriteDouble(writer, point.Value)
+	// Write isAbsent
+	VarintWrite(e.writer, (6<<3)+2)
+	VarintWrite(e.writer, uint64(count))
 
-			last = point.Time
+	last = start - step
+	for _, point := range points {
+		if point.Time < start || point.Time > stop {
+			continue
 		}
 
-		if stop > last {
-			ProtobufWriteDoubleN(writer, 0, int((stop-last)/step))
+		if point.Time > last+step {
+			WriteByteN(e.writer, '\x01', int(((point.Time-last)/step)-1))
 		}
 
-		// Write isAbsent
-		VarintWrite(writer, (6<<3)+2)
-		VarintWrite(writer, uint64(count))
+		e.writer.WriteByte('\x00')
 
-		last = start - step
-		for _, point := range points {
-			if point.Time < start || point.Time > stop {
-				continue
-			}
+		last = point.Time
+	}
 
-			if point.Time > last+step {
-				WriteByteN(writer, '\x01', int(((point.Time-last)/step)-1))
-			}
+	if stop > last {
+		WriteByteN(e.writer, '\x01', int((stop-last)/step))
+	}
+}
 
-			writer.WriteByte('\x00')
+func (e *protobufEncoder) finish() {}
 
-			last = point.Time
-		}
+func (h *Handler) ReplyProtobuf(w http.ResponseWriter, r *http.Request, data *Data, from, until uint32, prefix string, rollupObj *rollup.Rollup) {
+	points := data.Points.List()
 
-		if stop > last {
-			WriteByteN(writer, '\x01', int((stop-last)/step))
-		}
+	if len(points) == 0 {
+		return
+	}
+
+	// var multiResponse carbonzipperpb.MultiFetchResponse
+	writer := bufio.NewWriterSize(w, 1024*1024)
+	defer writer.Flush()
+
+	e := newProtobufEncoder(writer, from, until)
+
+	writeMetric := func(name string, points []point.Point) {
+		points, step := data.rollupMetric(rollupObj, from, until, points)
+		e.writeMetric(name, "", points, step)
 	}
 
 	// group by Metric
@@ -0,0 +1,337 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+// series is time series used in evaluation of graphite functions.
+// values[i] is value at start + i*step, NaN is absent value
+type series struct {
+	name           string
+	pathExpression string
+	start          uint32
+	step           uint32
+	values         []float64
+}
+
+func (s *series) copy() *series {
+	c := *s
+	c.values = make([]float64, len(s.values))
+	copy(c.values, s.values)
+	return &c
+}
+
+// rename returns copy of series with new name. Values are shared
+func (s *series) rename(name string) *series {
+	c := *s
+	c.name = name
+	c.pathExpression = name
+	return &c
+}
+
+// points returns present values of series
+func (s *series) points() []point.Point {
+	points := make([]point.Point, 0, len(s.values))
+	for i, v := range s.values {
+		if math.IsNaN(v) {
+			continue
+		}
+		points = append(points, point.Point{Time: s.start + uint32(i)*s.step, Value: v})
+	}
+	return points
+}
+
+// consolidate aggregates values into buckets of step aligned to step
+func (s *series) consolidate(step uint32, aggr aggregateFunc) *series {
+	c := *s
+	c.step = step
+	c.start = s.start - s.start%step
+	c.values = make([]float64, 0, len(s.values)*int(s.step)/int(step)+1)
+
+	bucket := make([]float64, 0, step/s.step+1)
+	bucketStart := c.start
+	for i, v := range s.values {
+		t := s.start + uint32(i)*s.step
+		for t >= bucketStart+step {
+			c.values = append(c.values, aggr(bucket))
+			bucket = bucket[:0]
+			bucketStart += step
+		}
+		bucket = append(bucket, v)
+	}
+	if len(bucket) > 0 {
+		c.values = append(c.values, aggr(bucket))
+	}
+
+	return &c
+}
+
+// aggregateFunc reduces values to one. NaN values are ignored, NaN is returned if all values are absent
+type aggregateFunc func(values []float64) float64
+
+func aggregateSum(values []float64) float64 {
+	r, n := 0.0, 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			r += v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return r
+}
+
+func aggregateAvg(values []float64) float64 {
+	r, n := 0.0, 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			r += v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return r / float64(n)
+}
+
+func aggregateMin(values []float64) float64 {
+	r := math.NaN()
+	for _, v := range values {
+		if !math.IsNaN(v) && (math.IsNaN(r) || v < r) {
+			r = v
+		}
+	}
+	return r
+}
+
+func aggregateMax(values []float64) float64 {
+	r := math.NaN()
+	for _, v := range values {
+		if !math.IsNaN(v) && (math.IsNaN(r) || v > r) {
+			r = v
+		}
+	}
+	return r
+}
+
+func aggregateCount(values []float64) float64 {
+	n := 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return float64(n)
+}
+
+func aggregateFirst(values []float64) float64 {
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			return v
+		}
+	}
+	return math.NaN()
+}
+
+func aggregateLast(values []float64) float64 {
+	for i := len(values) - 1; i >= 0; i-- {
+		if !math.IsNaN(values[i]) {
+			return values[i]
+		}
+	}
+	return math.NaN()
+}
+
+func aggregateMultiply(values []float64) float64 {
+	r, n := 1.0, 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			r *= v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return r
+}
+
+var aggregateFunctions = map[string]aggregateFunc{
+	"sum":      aggregateSum,
+	"total":    aggregateSum,
+	"avg":      aggregateAvg,
+	"average":  aggregateAvg,
+	"min":      aggregateMin,
+	"max":      aggregateMax,
+	"count":    aggregateCount,
+	"first":    aggregateFirst,
+	"last":     aggregateLast,
+	"multiply": aggregateMultiply,
+}
+
+// rollupAggregateFunc returns aggregate function for rollup function of clickhouse. Unknown function is avg
+func rollupAggregateFunc(name string) aggregateFunc {
+	switch name {
+	case "any":
+		return aggregateFirst
+	case "anyLast":
+		return aggregateLast
+	}
+	if f, ok := aggregateFunctions[name]; ok {
+		return f
+	}
+	return aggregateAvg
+}
+
+// getAggregateFunc returns aggregate function by graphite name. Suffix "Series" is allowed: sumSeries is sum
+func getAggregateFunc(name string) (aggregateFunc, error) {
+	f, ok := aggregateFunctions[strings.TrimSuffix(name, "Series")]
+	if !ok {
+		return nil, fmt.Errorf("unknown aggregation function %#v", name)
+	}
+	return f, nil
+}
+
+func gcd(a, b uint32) uint32 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// normalize consolidates series to least common multiple of their steps like graphite-web
+func normalize(list []*series) []*series {
+	if len(list) == 0 {
+		return list
+	}
+
+	step := list[0].step
+	for _, s := range list[1:] {
+		step = step / gcd(step, s.step) * s.step
+	}
+
+	result := make([]*series, len(list))
+	for i, s := range list {
+		if s.step == step {
+			result[i] = s
+		} else {
+			result[i] = s.consolidate(step, aggregateAvg)
+		}
+	}
+	return result
+}
+
+// combine aggregates list of series point by point into one series
+func combine(name string, list []*series, aggr aggregateFunc) *series {
+	list = normalize(list)
+
+	r := &series{name: name, pathExpression: name}
+	if len(list) == 0 {
+		return r
+	}
+
+	r.step = list[0].step
+	r.start = list[0].start
+	var end uint32
+	for _, s := range list {
+		if s.start < r.start {
+			r.start = s.start
+		}
+		if len(s.values) > 0 && s.start+uint32(len(s.values)-1)*s.step > end {
+			end = s.start + uint32(len(s.values)-1)*s.step
+		}
+	}
+	if end < r.start {
+		return r
+	}
+
+	r.values = make([]float64, (end-r.start)/r.step+1)
+	row := make([]float64, len(list))
+	for i := range r.values {
+		t := r.start + uint32(i)*r.step
+		for j, s := range list {
+			row[j] = math.NaN()
+			if t >= s.start {
+				k := int((t - s.start) / s.step)
+				if k < len(s.values) {
+					row[j] = s.values[k]
+				}
+			}
+		}
+		r.values[i] = aggr(row)
+	}
+
+	return r
+}
+
+// seriesFromData returns rolled up series of data by target
+func seriesFromData(data *Data, rollupObj *rollup.Rollup, from, until uint32) map[string][]*series {
+	result := make(map[string][]*series)
+
+	points := data.Points.List()
+	if len(points) == 0 {
+		return result
+	}
+
+	appendMetric := func(points []point.Point) {
+		a := data.Aliases[data.Points.MetricName(points[0].MetricID)]
+
+		points, step := data.rollupMetric(rollupObj, from, until, points)
+		start, end := stepBounds(from, until, step)
+
+		var values []float64
+		if end >= start {
+			values = make([]float64, 0, (end-start)/step+1)
+		}
+		alignValues(points, start, end, step, func(t uint32, value float64, isAbsent bool) {
+			if isAbsent {
+				values = append(values, math.NaN())
+			} else {
+				values = append(values, value)
+			}
+		})
+
+		for k := 0; k < len(a); k += 2 {
+			result[a[k+1]] = append(result[a[k+1]], &series{
+				name:           a[k],
+				pathExpression: a[k+1],
+				start:          start,
+				step:           step,
+				values:         values,
+			})
+		}
+	}
+
+	// group by Metric
+	var i, n int
+	// i - current position of iterator
+	// n - position of the first record with current metric
+	l := len(points)
+
+	for i = 1; i < l; i++ {
+		if points[i].MetricID != points[n].MetricID {
+			appendMetric(points[n:i])
+			n = i
+			continue
+		}
+	}
+	appendMetric(points[n:i])
+
+	for _, list := range result {
+		sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	}
+
+	return result
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesConsolidate(t *testing.T) {
+	assert := assert.New(t)
+
+	nan := math.NaN()
+	s := &series{name: "a", start: 60, step: 60, values: []float64{1, 2, nan, 4, 5}}
+
+	c := s.consolidate(120, aggregateSum)
+	assert.Equal(uint32(0), c.start)
+	assert.Equal(uint32(120), c.step)
+	assert.Equal([]float64{1, 2, 9}, c.values)
+
+	// source is not modified
+	assert.Equal(5, len(s.values))
+}
+
+func TestCombine(t *testing.T) {
+	assert := assert.New(t)
+
+	nan := math.NaN()
+	a := &series{name: "a", start: 0, step: 60, values: []float64{1, 2, 3, 4}}
+	b := &series{name: "b", start: 120, step: 120, values: []float64{10, nan}}
+
+	// a is normalized to 120 seconds with avg
+	r := combine("sum", []*series{a, b}, aggregateSum)
+	assert.Equal("sum", r.name)
+	assert.Equal(uint32(0), r.start)
+	assert.Equal(uint32(120), r.step)
+	if assert.Len(r.values, 3) {
+		assert.Equal([]float64{1.5, 13.5}, r.values[:2])
+		// last value of b is absent
+		assert.True(math.IsNaN(r.values[2]))
+	}
+}
+
+func TestGetAggregateFunc(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := getAggregateFunc("sumSeries")
+	assert.NoError(err)
+	assert.Equal(3.0, f([]float64{1, 2, math.NaN()}))
+
+	f, err = getAggregateFunc("average")
+	assert.NoError(err)
+	assert.Equal(1.5, f([]float64{1, 2, math.NaN()}))
+
+	assert.True(math.IsNaN(aggregateMax([]float64{math.NaN()})))
+
+	_, err = getAggregateFunc("unknown")
+	assert.Error(err)
+}
@@ -0,0 +1,170 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/helper/log"
+	"github.com/lomik/graphite-clickhouse/helper/point"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+// replyEncoder writes reply metric by metric
+type replyEncoder interface {
+	start()
+	writeMetric(name string, pathExpression string, points []point.Point, step uint32)
+	finish()
+}
+
+// IsStreamableFormat returns true if reply in format can be written metric by metric
+func IsStreamableFormat(format string) bool {
+	switch format {
+	case "pickle", "protobuf", "carbonapi_v2_pb":
+		return true
+	}
+	return false
+}
+
+// writtenWriter remembers if anything was written to response
+type writtenWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (w *writtenWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.w.Write(p)
+}
+
+// replyStream reads points ordered by Path and Time from requests one after another and writes reply
+// metric by metric, so only points of one metric are kept in memory. Response is aborted if error
+// occurs after part of reply is sent
+func (h *Handler) replyStream(w http.ResponseWriter, r *http.Request, requests []dataRequest, open func(ctx context.Context, req dataRequest) (io.ReadCloser, error), extraPoints *point.Points, aliases map[string][]string, isReverse bool, from, until uint32, maxDataPoints int, rollupObj *rollup.Rollup) {
+	logger := log.FromContext(r.Context())
+
+	// points from carbonlink by metric
+	extra := make(map[string][]point.Point)
+	if extraPoints != nil {
+		for _, p := range extraPoints.List() {
+			name := extraPoints.MetricName(p.MetricID)
+			extra[name] = append(extra[name], p)
+		}
+	}
+
+	ww := &writtenWriter{w: w}
+	writer := bufio.NewWriterSize(ww, 1024*1024)
+
+	var e replyEncoder
+	if r.FormValue("format") == "pickle" {
+		e = newPickleEncoder(writer, from, until)
+	} else {
+		e = newProtobufEncoder(writer, from, until)
+	}
+
+	writeMetric := func(name string, points []point.Point) {
+		if p, ok := extra[name]; ok {
+			points = append(points, p...)
+			sort.SliceStable(points, func(i, j int) bool { return points[i].Time < points[j].Time })
+			delete(extra, name)
+		}
+		points = point.Uniq(points)
+
+		points, step := rollupObj.RollupMetricMaxDataPoints(name, from, until, maxDataPoints, points)
+
+		a := aliases[name]
+		for k := 0; k < len(a); k += 2 {
+			e.writeMetric(a[k], a[k+1], points, step)
+		}
+	}
+
+	readRequest := func(req dataRequest, points []point.Point) ([]point.Point, error) {
+		body, err := open(r.Context(), req)
+		if err != nil {
+			return points, err
+		}
+		defer body.Close()
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 1048576), 1048576)
+		scanner.Split(DataSplitFunc)
+
+		var rawName []byte // name as in clickhouse
+		var name string
+
+		for scanner.Scan() {
+			rowName, time, value, timestamp, err := parseRow(scanner.Bytes())
+			if err != nil {
+				return points, err
+			}
+
+			if len(points) == 0 || !bytes.Equal(rowName, rawName) {
+				if len(points) > 0 {
+					writeMetric(name, points)
+				}
+				rawName = append(rawName[:0], rowName...)
+				name = string(rawName)
+				if isReverse {
+					name = reversePath(name)
+				}
+				points = points[:0]
+			}
+
+			points = append(points, point.Point{MetricID: 1, Value: value, Time: time, Timestamp: timestamp})
+		}
+
+		if err := scanner.Err(); err != nil {
+			return points, err
+		}
+
+		if len(points) > 0 {
+			writeMetric(name, points)
+		}
+
+		return points[:0], nil
+	}
+
+	fail := func(err error) {
+		if !ww.written {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Error("stream reply failed", zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+
+	e.start()
+
+	// points of current metric
+	points := make([]point.Point, 0)
+
+	for _, req := range requests {
+		var err error
+		points, err = readRequest(req, points)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	// metrics found in carbonlink only
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeMetric(name, nil)
+	}
+
+	e.finish()
+
+	if err := writer.Flush(); err != nil {
+		logger.Info("stream reply write failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+	"github.com/lomik/graphite-clickhouse/helper/rollup"
+)
+
+func TestReplyStream(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := rollup.ParseXML([]byte(`
+<graphite_rollup>
+	<default>
+		<function>avg</function>
+		<retention>
+			<age>0</age>
+			<precision>60</precision>
+		</retention>
+	</default>
+</graphite_rollup>`))
+	assert.NoError(err)
+
+	var from uint32 = 1520056800
+	until := from + 599
+
+	// sorted by Path and Time with duplicates
+	data := makeData([]testPoint{
+		{"a.b.c", 1, from, 10},
+		{"a.b.c", 2, from, 20},
+		{"a.b.c", 3, from + 60, 10},
+		{"a.b.d", 4, from + 120, 10},
+		{"a.b.d", 5, from + 180, 10},
+	})
+
+	srv := clickhouse.NewTestServer()
+	defer srv.Close()
+
+	var response []byte
+	srv.SetResponse(func(query []byte) []byte {
+		if bytes.Contains(query, []byte("graphite_tree")) {
+			return []byte("a.b.c\na.b.d\n")
+		}
+		return response
+	})
+
+	render := func(streaming bool, format string) (int, []byte) {
+		cfg := config.New()
+		cfg.ClickHouse.Url = srv.URL
+		cfg.ClickHouse.RenderStreaming = streaming
+		cfg.Rollup = r
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/render/?target=a.b.*&from=%d&until=%d&format=%s", from, until, format), nil)
+		w := httptest.NewRecorder()
+		NewHandler(cfg).ServeHTTP(w, req)
+
+		return w.Code, w.Body.Bytes()
+	}
+
+	response = data
+	for _, format := range []string{"pickle", "protobuf"} {
+		code, expected := render(false, format)
+		assert.Equal(200, code)
+		assert.Contains(string(expected), "a.b.d", format)
+
+		code, body := render(true, format)
+		assert.Equal(200, code)
+		assert.Equal(expected, body, format)
+
+		requests := srv.Requests()
+		assert.Contains(string(requests[len(requests)-1].Query), "ORDER BY Path, Time")
+	}
+
+	// error before reply is written
+	response = []byte{5}
+	code, _ := render(true, "pickle")
+	assert.Equal(500, code)
+}
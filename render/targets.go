@@ -0,0 +1,80 @@
+package render
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+)
+
+// findTargets resolves targets to series concurrently, at most concurrency finds at once.
+// Returns map of series to pairs of (abs, target) in order of targets. Remaining finds are
+// canceled on first error
+func findTargets(ctx context.Context, cfg *config.Config, targets []string, from int64, until int64, concurrency int) (map[string][]string, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]finder.Result, len(targets))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			// don't start find if sibling already failed
+			if ctx.Err() != nil {
+				return
+			}
+
+			res, err := finder.Find(cfg, ctx, target, from, until)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = res
+		}(i, target)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string][]string)
+
+	for i, target := range targets {
+		series := results[i].Series()
+
+		for j := 0; j < len(series); j++ {
+			key := string(series[j])
+			abs := string(results[i].Abs(series[j]))
+			aliases[key] = append(aliases[key], abs, target)
+		}
+	}
+
+	return aliases, nil
+}
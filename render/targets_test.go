@@ -0,0 +1,64 @@
+package render
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+)
+
+func TestFindTargets(t *testing.T) {
+	assert := assert.New(t)
+
+	var inflight, maxInflight int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		query := string(body)
+		switch {
+		case strings.Contains(query, "fail"):
+			http.Error(w, "Code: 1000, e.displayText() = fail", http.StatusInternalServerError)
+		case strings.Contains(query, "x.y"):
+			w.Write([]byte("a.b.c\nx.y\n"))
+		default:
+			w.Write([]byte("a.b.c\n"))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+
+	targets := []string{"a.b.c", "x.y", "a.b.c", "a.b.c"}
+	aliases, err := findTargets(context.Background(), cfg, targets, 0, 0, 2)
+	assert.NoError(err)
+
+	// pairs of (abs, target) are in order of targets
+	assert.Equal(map[string][]string{
+		"a.b.c": {"a.b.c", "a.b.c", "a.b.c", "x.y", "a.b.c", "a.b.c", "a.b.c", "a.b.c"},
+		"x.y":   {"x.y", "x.y"},
+	}, aliases)
+	assert.True(atomic.LoadInt32(&maxInflight) <= 2)
+
+	_, err = findTargets(context.Background(), cfg, []string{"a.b.c", "fail", "x.y"}, 0, 0, 2)
+	assert.Error(err)
+}
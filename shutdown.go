@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+
+	"github.com/lomik/graphite-clickhouse/health"
+)
+
+// cancelGrace is time for canceled requests to finish after shutdown timeout
+const cancelGrace = time.Second
+
+// serve runs http server until SIGTERM or SIGINT, see shutdown
+func serve(listen string, ready *health.Ready, delay func() time.Duration, timeout func() time.Duration, stop ...func()) error {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	return serveListener(ln, http.DefaultServeMux, signals, ready, delay, timeout, stop...)
+}
+
+// serveListener serves handler on ln until signal. On signal server reports not ready and keeps accepting
+// connections for delay, so load balancer removes node before it stops listening. Then server stops
+// accepting new connections and waits for running requests up to timeout.
+// Requests still running after timeout are canceled with their clickhouse queries.
+// Background workers are stopped with stop functions after the server
+func serveListener(ln net.Listener, handler http.Handler, signals <-chan os.Signal, ready *health.Ready, delay func() time.Duration, timeout func() time.Duration, stop ...func()) error {
+	baseCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := &http.Server{
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- srv.Serve(ln)
+	}()
+
+	var sig os.Signal
+	select {
+	case err := <-listenErr:
+		return err
+	case sig = <-signals:
+	}
+
+	logger := zapwriter.Logger("shutdown")
+	logger.Info("shutting down",
+		zap.String("signal", sig.String()),
+		zap.Duration("delay", delay()),
+		zap.Duration("timeout", timeout()),
+	)
+
+	ready.Shutdown()
+	time.Sleep(delay())
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), timeout())
+	defer cancelTimeout()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warn("running requests are canceled", zap.Error(err))
+
+		// cancel contexts of running requests and their clickhouse queries
+		cancel()
+		time.Sleep(cancelGrace)
+		srv.Close()
+	}
+
+	for _, f := range stop {
+		f()
+	}
+
+	logger.Info("stopped")
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/health"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+func TestServeShutdown(t *testing.T) {
+	assert := assert.New(t)
+
+	ch := clickhouse.NewTestServer()
+	defer ch.Close()
+
+	// all tables have all columns
+	ch.SetResponse(func(query []byte) []byte {
+		return []byte("Path\nValue\nTime\nDate\nTimestamp\nLevel\nDeleted\n")
+	})
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = ch.URL
+	cfg.ClickHouse.TreeTable = ""
+
+	ready := health.NewReady(func() *config.Config { return cfg }, time.Minute)
+	assert.NoError(ready.Start())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.Handle("/ready", ready)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(err) {
+		return
+	}
+	url := "http://" + ln.Addr().String()
+
+	signals := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	served := make(chan error, 1)
+
+	go func() {
+		served <- serveListener(ln, mux, signals, ready,
+			func() time.Duration { return 500 * time.Millisecond },
+			func() time.Duration { return 10 * time.Second },
+			ready.Stop,
+			func() { close(stopped) },
+		)
+	}()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(url + path)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// wait for the first check
+	code, body := get("/ready")
+	for i := 0; i < 100 && code != http.StatusOK; i++ {
+		time.Sleep(10 * time.Millisecond)
+		code, body = get("/ready")
+	}
+	assert.Equal(http.StatusOK, code, body)
+
+	type response struct {
+		code int
+		body string
+	}
+	slow := make(chan response, 1)
+	go func() {
+		code, body := get("/slow")
+		slow <- response{code, body}
+	}()
+	<-started
+
+	signals <- syscall.SIGTERM
+
+	// not ready while listener still accepts connections
+	code, _ = get("/ready")
+	assert.Equal(http.StatusServiceUnavailable, code)
+
+	// running request is finished
+	close(release)
+	assert.Equal(response{http.StatusOK, "done"}, <-slow)
+
+	select {
+	case err := <-served:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server is not stopped")
+	}
+
+	// workers are stopped after server
+	select {
+	case <-stopped:
+	default:
+		t.Error("stop functions are not called")
+	}
+
+	_, err = net.Dial("tcp", ln.Addr().String())
+	assert.Error(err)
+}
@@ -0,0 +1,283 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+// listParams are common parameters of tag browsing API
+type listParams struct {
+	from   int64
+	until  int64
+	limit  int
+	filter *regexp.Regexp // nil - no filter
+}
+
+// parseListParams parses from, until, limit and filter parameters.
+// Default time range is last tagged-autocomplete-days days
+func parseListParams(r *http.Request, cfg *config.Config) (*listParams, error) {
+	var err error
+
+	r.ParseMultipartForm(1024 * 1024)
+
+	p := &listParams{
+		until: time.Now().Unix(),
+		limit: 10000,
+	}
+	p.from = time.Unix(p.until, 0).AddDate(0, 0, -cfg.ClickHouse.TaggedAutocompleDays).Unix()
+
+	if r.FormValue("from") != "" {
+		p.from, err = strconv.ParseInt(r.FormValue("from"), 10, 32)
+		if err != nil {
+			return nil, errors.New("Bad request (malformed from)")
+		}
+	}
+
+	if r.FormValue("until") != "" {
+		p.until, err = strconv.ParseInt(r.FormValue("until"), 10, 32)
+		if err != nil {
+			return nil, errors.New("Bad request (malformed until)")
+		}
+	}
+
+	if r.FormValue("limit") != "" {
+		p.limit, err = strconv.Atoi(r.FormValue("limit"))
+		if err != nil {
+			return nil, errors.New("Bad request (malformed limit)")
+		}
+	}
+
+	if r.FormValue("filter") != "" {
+		p.filter, err = regexp.Compile(r.FormValue("filter"))
+		if err != nil {
+			return nil, fmt.Errorf("Bad request (malformed filter: %s)", err.Error())
+		}
+	}
+
+	return p, nil
+}
+
+func (p *listParams) dateWhere() string {
+	return fmt.Sprintf(
+		"Date >='%s' AND Date <= '%s'",
+		time.Unix(p.from, 0).Format("2006-01-02"),
+		time.Unix(p.until, 0).Format("2006-01-02"),
+	)
+}
+
+// TagsHandler serves graphite-web tag browsing API: list of tags on /tags and values of tag with
+// count of series on /tags/<tag>
+type TagsHandler struct {
+	config *config.Config
+}
+
+func NewTags(config *config.Config) *TagsHandler {
+	return &TagsHandler{
+		config: config,
+	}
+}
+
+func (h *TagsHandler) query(r *http.Request, sql string) ([]string, error) {
+	body, err := clickhouse.Query(r.Context(), h.config.ClickHouse.Url, sql, h.config.ClickHouse.TaggedTable,
+		clickhouse.Options{Timeout: h.config.ClickHouse.TreeTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := strings.Split(string(body), "\n")
+	if len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+func (h *TagsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.config.ClickHouse.TaggedTable == "" {
+		http.Error(w, "tagged-table is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	p, err := parseListParams(r, h.config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags"), "/")
+	if tag == "" {
+		h.serveTags(w, r, p)
+	} else {
+		h.serveValues(w, r, p, tag)
+	}
+}
+
+type tagItem struct {
+	Tag string `json:"tag"`
+}
+
+func (h *TagsHandler) serveTags(w http.ResponseWriter, r *http.Request, p *listParams) {
+	where := finder.NewWhere()
+	where.And(p.dateWhere())
+	where.And("Deleted = 0")
+
+	sql := fmt.Sprintf("SELECT %s AS value FROM %s %s GROUP BY value ORDER BY value",
+		finder.TagNameSQL("Tag1"),
+		h.config.ClickHouse.TaggedTable,
+		where.SQL(),
+	)
+
+	rows, err := h.query(r, sql)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tags := make([]string, 0, len(rows))
+	for _, tag := range rows {
+		if tag == "__name__" {
+			tag = "name"
+		}
+		if p.filter != nil && !p.filter.MatchString(tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	sort.Strings(tags)
+	if len(tags) > p.limit {
+		tags = tags[:p.limit]
+	}
+
+	result := make([]tagItem, len(tags))
+	for i, tag := range tags {
+		result[i].Tag = tag
+	}
+
+	writeJSON(w, result)
+}
+
+type valueItem struct {
+	Count uint64 `json:"count"`
+	Value string `json:"value"`
+}
+
+type tagValues struct {
+	Tag    string      `json:"tag"`
+	Values []valueItem `json:"values"`
+}
+
+func (h *TagsHandler) serveValues(w http.ResponseWriter, r *http.Request, p *listParams, tag string) {
+	tag1 := tag
+	if tag1 == "name" {
+		tag1 = "__name__"
+	}
+
+	value := finder.TagValueSQL("Tag1", tag1)
+
+	where := finder.NewWhere()
+	where.And(p.dateWhere())
+	where.And(finder.TagValueWhere("Tag1", tag1, ""))
+	where.And("Deleted = 0")
+	if p.filter != nil {
+		where.Andf("match(%s, %s)", value, finder.Q(p.filter.String()))
+	}
+
+	sql := fmt.Sprintf("SELECT %s AS value, uniqExact(Path) FROM %s %s GROUP BY value ORDER BY value LIMIT %d",
+		value,
+		h.config.ClickHouse.TaggedTable,
+		where.SQL(),
+		p.limit,
+	)
+
+	rows, err := h.query(r, sql)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := tagValues{
+		Tag:    tag,
+		Values: make([]valueItem, 0, len(rows)),
+	}
+
+	for _, row := range rows {
+		p := strings.LastIndexByte(row, '\t')
+		if p < 0 {
+			continue
+		}
+		count, err := strconv.ParseUint(row[p+1:], 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Values = append(result.Values, valueItem{Count: count, Value: row[:p]})
+	}
+
+	writeJSON(w, result)
+}
+
+// FindSeriesHandler serves graphite-web /tags/findSeries. Returns series matched all of expr parameters
+type FindSeriesHandler struct {
+	config *config.Config
+}
+
+func NewFindSeries(config *config.Config) *FindSeriesHandler {
+	return &FindSeriesHandler{
+		config: config,
+	}
+}
+
+func (h *FindSeriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.config.ClickHouse.TaggedTable == "" {
+		http.Error(w, "tagged-table is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	p, err := parseListParams(r, h.config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expr := make([]string, 0)
+	for _, e := range r.Form["expr"] {
+		if e != "" {
+			expr = append(expr, e)
+		}
+	}
+	if len(expr) == 0 {
+		http.Error(w, "Bad request (no expr)", http.StatusBadRequest)
+		return
+	}
+
+	f := finder.NewTagged(h.config.ClickHouse.Url, h.config.ClickHouse.TaggedTable,
+		clickhouse.Options{Timeout: h.config.ClickHouse.TreeTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()})
+
+	if err := f.ExecuteExpr(r.Context(), expr, p.from, p.until); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list := f.List()
+	series := make([]string, 0, len(list))
+	for _, path := range list {
+		series = append(series, string(f.Abs(path)))
+	}
+
+	sort.Strings(series)
+	if len(series) > p.limit {
+		series = series[:p.limit]
+	}
+
+	writeJSON(w, series)
+}
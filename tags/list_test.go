@@ -0,0 +1,136 @@
+package tags
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+)
+
+func newListServer(response string) (*httptest.Server, *[]string) {
+	queries := make([]string, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		queries = append(queries, string(body))
+		fmt.Fprint(w, response)
+	}))
+	return srv, &queries
+}
+
+func get(h http.Handler, url string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestTagsList(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, queries := newListServer("__name__\ndatacenter\nrack\n")
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+
+	w := get(NewTags(cfg), "/tags?from=1520000000&until=1520100000")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`[{"tag":"datacenter"},{"tag":"name"},{"tag":"rack"}]`, w.Body.String())
+
+	if assert.Len(*queries, 1) {
+		assert.Contains((*queries)[0], "FROM graphite_tagged WHERE (Date >='2018-03-02' AND Date <= '2018-03-03') AND (Deleted = 0)")
+	}
+
+	w = get(NewTags(cfg), "/tags/?filter=^r&limit=1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`[{"tag":"rack"}]`, w.Body.String())
+}
+
+func TestTagValues(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, queries := newListServer("dc1\t10\ndc2\t3\n")
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+
+	w := get(NewTags(cfg), "/tags/datacenter?filter=dc&limit=5")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`{"tag":"datacenter","values":[{"count":10,"value":"dc1"},{"count":3,"value":"dc2"}]}`, w.Body.String())
+
+	if assert.Len(*queries, 1) {
+		q := (*queries)[0]
+		assert.Contains(q, "SELECT substring(Tag1, 12) AS value")
+		assert.Contains(q, "(startsWith(Tag1, 'datacenter='))")
+		assert.Contains(q, "(match(substring(Tag1, 12), 'dc'))")
+		assert.Contains(q, "LIMIT 5")
+	}
+
+	get(NewTags(cfg), "/tags/name")
+	if assert.Len(*queries, 2) {
+		assert.Contains((*queries)[1], "(startsWith(Tag1, '__name__='))")
+		assert.Contains((*queries)[1], "SELECT substring(Tag1, 10) AS value")
+	}
+}
+
+func TestTagValuesWithEquals(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, queries := newListServer("a=b\t2\nx=y=z\t1\n")
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+
+	w := get(NewTags(cfg), "/tags/my_tag?filter=%3D")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`{"tag":"my_tag","values":[{"count":2,"value":"a=b"},{"count":1,"value":"x=y=z"}]}`, w.Body.String())
+
+	if assert.Len(*queries, 1) {
+		q := (*queries)[0]
+		// '_' and '%' in tag name are not wildcards, value is not cut at '='
+		assert.NotContains(q, "LIKE")
+		assert.NotContains(q, "splitByChar")
+		assert.Contains(q, "(startsWith(Tag1, 'my_tag='))")
+		assert.Contains(q, "(match(substring(Tag1, 8), '='))")
+	}
+}
+
+func TestFindSeries(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, queries := newListServer("disk.used?rack=a1\ndisk.used?datacenter=dc1&rack=a1\n")
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+
+	w := get(NewFindSeries(cfg), "/tags/findSeries?expr=name=disk.used&expr=rack=a1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`["disk.used;datacenter=dc1;rack=a1","disk.used;rack=a1"]`, w.Body.String())
+
+	if assert.Len(*queries, 1) {
+		assert.True(strings.HasPrefix((*queries)[0], "SELECT Path FROM graphite_tagged"))
+	}
+
+	w = get(NewFindSeries(cfg), "/tags/findSeries")
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestListNotConfigured(t *testing.T) {
+	cfg := config.New()
+
+	assert.Equal(t, http.StatusNotImplemented, get(NewTags(cfg), "/tags").Code)
+	assert.Equal(t, http.StatusNotImplemented, get(NewFindSeries(cfg), "/tags/findSeries?expr=a=b").Code)
+}
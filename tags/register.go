@@ -0,0 +1,183 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lomik/graphite-clickhouse/config"
+	"github.com/lomik/graphite-clickhouse/helper/RowBinary"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+// RegisterHandler serves graphite-web /tags/tagSeries and /tags/tagMultiSeries.
+// Series are written to tagged-table, clickhouse user must have write permission
+type RegisterHandler struct {
+	config *config.Config
+	multi  bool
+}
+
+func NewTagSeries(config *config.Config) *RegisterHandler {
+	return &RegisterHandler{
+		config: config,
+	}
+}
+
+func NewTagMultiSeries(config *config.Config) *RegisterHandler {
+	return &RegisterHandler{
+		config: config,
+		multi:  true,
+	}
+}
+
+// parsePaths parses all path parameters of POST request
+func parsePaths(w http.ResponseWriter, r *http.Request, cfg *config.Config) ([]*Series, bool) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	if cfg.ClickHouse.TaggedTable == "" {
+		http.Error(w, "tagged-table is not configured", http.StatusNotImplemented)
+		return nil, false
+	}
+
+	r.ParseMultipartForm(1024 * 1024)
+
+	paths := r.Form["path"]
+	if len(paths) == 0 {
+		http.Error(w, "Bad request (no path)", http.StatusBadRequest)
+		return nil, false
+	}
+
+	series := make([]*Series, 0, len(paths))
+	for _, p := range paths {
+		s, err := Parse(p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		series = append(series, s)
+	}
+
+	return series, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (h *RegisterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	series, ok := parsePaths(w, r, h.config)
+	if !ok {
+		return
+	}
+
+	if !h.multi {
+		series = series[:1]
+	}
+
+	now := time.Now()
+	version := uint32(now.Unix())
+	today := RowBinary.DateToUint16(now)
+
+	body := new(bytes.Buffer)
+	rows := RowBinary.NewEncoder(body)
+
+	result := make([]string, 0, len(series))
+
+	for _, s := range series {
+		path := s.Path()
+		tags := s.TagList()
+
+		// INSERT INTO graphite_tagged (Date, Tag1, Path, Tags, Version)
+		for _, tag := range tags {
+			rows.Uint16(today)
+			rows.String(tag)
+			rows.String(path)
+			rows.StringList(tags)
+			rows.Uint32(version)
+		}
+
+		result = append(result, s.String())
+	}
+
+	table := h.config.ClickHouse.TaggedTable
+
+	_, err := clickhouse.Post(
+		r.Context(),
+		h.config.ClickHouse.Url,
+		fmt.Sprintf("INSERT INTO %s (Date, Tag1, Path, Tags, Version) FORMAT RowBinary", table),
+		table,
+		body,
+		clickhouse.Options{Timeout: h.config.ClickHouse.TreeTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if h.multi {
+		writeJSON(w, result)
+	} else {
+		writeJSON(w, result[0])
+	}
+}
+
+// DelHandler serves graphite-web /tags/delSeries. Rows of series in tagged-table are copied
+// with Deleted=1 and new Version
+type DelHandler struct {
+	config *config.Config
+}
+
+func NewDelSeries(config *config.Config) *DelHandler {
+	return &DelHandler{
+		config: config,
+	}
+}
+
+func (h *DelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	series, ok := parsePaths(w, r, h.config)
+	if !ok {
+		return
+	}
+
+	paths := make([]string, 0, len(series))
+	for _, s := range series {
+		paths = append(paths, "'"+clickhouse.Escape(s.Path())+"'")
+	}
+
+	table := h.config.ClickHouse.TaggedTable
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (Date, Tag1, Path, Tags, Version, Deleted) SELECT Date, Tag1, Path, Tags, %d, 1 FROM %s WHERE (Path IN (%s)) AND (Deleted = 0)",
+		table,
+		time.Now().Unix(),
+		table,
+		strings.Join(paths, ","),
+	)
+
+	_, err := clickhouse.Query(
+		r.Context(),
+		h.config.ClickHouse.Url,
+		query,
+		table,
+		clickhouse.Options{Timeout: h.config.ClickHouse.TreeTimeout.Value(), ConnectTimeout: h.config.ClickHouse.ConnectTimeout.Value()},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, true)
+}
@@ -0,0 +1,98 @@
+package tags
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/config"
+)
+
+type request struct {
+	query string
+	body  []byte
+}
+
+func newServer() (*httptest.Server, *[]request) {
+	requests := make([]request, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{query: r.URL.Query().Get("query"), body: body})
+	}))
+	return srv, &requests
+}
+
+func post(h http.Handler, paths ...string) *httptest.ResponseRecorder {
+	form := url.Values{"path": paths}
+	req := httptest.NewRequest("POST", "/tags/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestTagSeries(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, requests := newServer()
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+
+	w := post(NewTagSeries(cfg), "disk.used;rack=a1;datacenter=dc1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`"disk.used;datacenter=dc1;rack=a1"`, w.Body.String())
+
+	if assert.Len(*requests, 1) {
+		r := (*requests)[0]
+		assert.Equal("INSERT INTO graphite_tagged (Date, Tag1, Path, Tags, Version) FORMAT RowBinary", r.query)
+		// row for each of 3 tags
+		assert.Equal(3, bytes.Count(r.body, []byte("disk.used?datacenter=dc1&rack=a1")))
+	}
+
+	w = post(NewTagMultiSeries(cfg), "cpu;host=a", "mem;host=b")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`["cpu;host=a","mem;host=b"]`, w.Body.String())
+
+	w = post(NewTagSeries(cfg), "cpu;host")
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	// not configured
+	cfg.ClickHouse.TaggedTable = ""
+	w = post(NewTagSeries(cfg), "cpu;host=a")
+	assert.Equal(http.StatusNotImplemented, w.Code)
+}
+
+func TestDelSeries(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, requests := newServer()
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.ClickHouse.Url = srv.URL
+	cfg.ClickHouse.TaggedTable = "graphite_tagged"
+
+	w := post(NewDelSeries(cfg), "disk.used;rack=a1;datacenter=dc1", "cpu")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("true", w.Body.String())
+
+	if assert.Len(*requests, 1) {
+		query := string((*requests)[0].body)
+		assert.Contains(query, "INSERT INTO graphite_tagged (Date, Tag1, Path, Tags, Version, Deleted) SELECT Date, Tag1, Path, Tags, ")
+		assert.Contains(query, "WHERE (Path IN ('disk.used?datacenter=dc1&rack=a1','cpu')) AND (Deleted = 0)")
+	}
+
+	req := httptest.NewRequest("GET", "/tags/delSeries?path=cpu", nil)
+	w = httptest.NewRecorder()
+	NewDelSeries(cfg).ServeHTTP(w, req)
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+}
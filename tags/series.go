@@ -0,0 +1,75 @@
+package tags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lomik/graphite-clickhouse/finder"
+)
+
+// Series is tagged series in graphite form "name;tag1=value1;tag2=value2"
+type Series struct {
+	Name string
+	Tags map[string]string // without name
+}
+
+// Parse parses series in graphite form. Last value of duplicated tag is used
+func Parse(path string) (*Series, error) {
+	parts := strings.Split(path, ";")
+
+	s := &Series{
+		Name: parts[0],
+		Tags: make(map[string]string),
+	}
+
+	if s.Name == "" {
+		return nil, fmt.Errorf("Bad series %#v: empty name", path)
+	}
+
+	for _, tag := range parts[1:] {
+		p := strings.IndexByte(tag, '=')
+		if p < 1 || p == len(tag)-1 {
+			return nil, fmt.Errorf("Bad series %#v: tag %#v is not name=value", path, tag)
+		}
+		if strings.ContainsAny(tag[:p], "!^") {
+			return nil, fmt.Errorf("Bad series %#v: invalid tag name %#v", path, tag[:p])
+		}
+		if tag[:p] == "name" || tag[:p] == "__name__" {
+			return nil, fmt.Errorf("Bad series %#v: tag %#v is reserved", path, tag[:p])
+		}
+		s.Tags[tag[:p]] = tag[p+1:]
+	}
+
+	return s, nil
+}
+
+func (s *Series) tags() []string {
+	tags := make([]string, 0, len(s.Tags))
+	for k, v := range s.Tags {
+		tags = append(tags, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// String returns normalized graphite form with sorted tags, same as TaggedFinder.Abs returns
+func (s *Series) String() string {
+	tags := s.tags()
+	if len(tags) == 0 {
+		return s.Name
+	}
+	return fmt.Sprintf("%s;%s", s.Name, strings.Join(tags, ";"))
+}
+
+// Path returns Path of series in tagged table: "name?tag1=value1&tag2=value2"
+func (s *Series) Path() string {
+	path, _ := finder.TaggedPath(s.Name, s.Tags)
+	return path
+}
+
+// TagList returns Tags column of tagged table with name as __name__ tag
+func (s *Series) TagList() []string {
+	_, tags := finder.TaggedPath(s.Name, s.Tags)
+	return tags
+}
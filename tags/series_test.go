@@ -0,0 +1,50 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lomik/graphite-clickhouse/finder"
+	"github.com/lomik/graphite-clickhouse/helper/clickhouse"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	table := []struct {
+		path       string
+		normalized string
+		taggedPath string
+		tags       []string
+	}{
+		{"disk.used", "disk.used", "disk.used", []string{"__name__=disk.used"}},
+		{
+			"disk.used;rack=a1;datacenter=dc1;server=web01",
+			"disk.used;datacenter=dc1;rack=a1;server=web01",
+			"disk.used?datacenter=dc1&rack=a1&server=web01",
+			[]string{"__name__=disk.used", "datacenter=dc1", "rack=a1", "server=web01"},
+		},
+		// last value of duplicated tag
+		{"cpu;host=a;host=b", "cpu;host=b", "cpu?host=b", []string{"__name__=cpu", "host=b"}},
+		{"cpu;path=/var lib", "cpu;path=/var lib", "cpu?path=%2Fvar+lib", []string{"__name__=cpu", "path=/var lib"}},
+	}
+
+	for _, test := range table {
+		s, err := Parse(test.path)
+		if !assert.NoError(err, test.path) {
+			continue
+		}
+		assert.Equal(test.normalized, s.String(), test.path)
+		assert.Equal(test.taggedPath, s.Path(), test.path)
+		assert.Equal(test.tags, s.TagList(), test.path)
+
+		// path in tagged table is returned by finder in the same normalized form
+		assert.Equal(test.normalized, string(finder.NewTagged("", "", clickhouse.Options{}).Abs([]byte(s.Path()))), test.path)
+	}
+
+	for _, path := range []string{"", ";a=b", "cpu;host", "cpu;host=", "cpu;=a", "cpu;name=a", "cpu;a!=b"} {
+		_, err := Parse(path)
+		assert.Error(err, path)
+	}
+}